@@ -0,0 +1,98 @@
+package main
+
+import (
+	"contract-listener/internal/repo"
+	"contract-listener/pkg/config"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// 死信队列管理：
+//
+//	app dlq list [--status pending|failed|done|all] [--limit 50]
+//	app dlq requeue --id N | --all-failed
+func runDLQ(args []string) int {
+
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: app dlq list|requeue [flags]")
+		return exitError
+	}
+
+	cfg := config.Load()
+	if err := repo.InitDB(cfg.DB.DSN); err != nil {
+		log.Println("init db failed:", err)
+		return exitError
+	}
+
+	switch args[0] {
+	case "list":
+		return dlqList(args[1:])
+	case "requeue":
+		return dlqRequeue(args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, "unknown dlq command:", args[0])
+		return exitError
+	}
+}
+
+func dlqList(args []string) int {
+
+	fs := flag.NewFlagSet("dlq list", flag.ContinueOnError)
+	status := fs.String("status", "pending", "pending, failed, done or all")
+	limit := fs.Int("limit", 50, "max rows")
+
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *status == "all" {
+		*status = ""
+	}
+
+	list, err := repo.ListDeadLetters(*status, *limit)
+	if err != nil {
+		log.Println("list dead letters failed:", err)
+		return exitError
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCHAIN\tBLOCK\tTX\tINDEX\tEVENT\tSTATUS\tATTEMPTS\tNEXT RETRY\tERROR")
+	for _, d := range list {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%d\t%s\t%s\t%d\t%s\t%s\n",
+			d.ID, d.ChainID, d.Block, d.TxHash, d.LogIndex, d.Event, d.Status, d.Attempts,
+			d.NextRetryAt.Local().Format(time.DateTime), d.Error)
+	}
+	w.Flush()
+
+	return exitOK
+}
+
+func dlqRequeue(args []string) int {
+
+	fs := flag.NewFlagSet("dlq requeue", flag.ContinueOnError)
+	id := fs.Int64("id", 0, "dead letter id")
+	allFailed := fs.Bool("all-failed", false, "requeue every failed dead letter")
+
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	if (*id == 0) == !*allFailed {
+		fmt.Fprintln(os.Stderr, "dlq requeue: use exactly one of --id or --all-failed")
+		return exitError
+	}
+
+	n, err := repo.RequeueDeadLetters(*id)
+	if err != nil {
+		log.Println("requeue dead letters failed:", err)
+		return exitError
+	}
+
+	fmt.Println("requeued", n)
+
+	return exitOK
+}
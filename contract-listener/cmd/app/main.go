@@ -1,96 +1,257 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"contract-listener/internal/chain"
-	"contract-listener/internal/service"
-	"contract-listener/internal/worker"
+	"contract-listener/internal/listener"
+	"contract-listener/internal/notify"
+	"contract-listener/internal/ops"
+	"contract-listener/internal/repo"
+	"contract-listener/internal/webhook"
 	"contract-listener/pkg/config"
+	"errors"
 	"log"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
 
-	"github.com/ethereum/go-ethereum/accounts/abi"
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
+// 退出码，供 systemd / 守护进程判断是否需要告警
+const (
+	exitOK           = 0
+	exitError        = 1 // 启动失败、检查点保存失败
+	exitDrainTimeout = 2 // 截止时间内队列没处理完，检查点只推进到已处理部分
 )
 
+// 常驻任务：合约日志监听、交易 calldata 索引
+type runner interface {
+	Name() string
+	Ping(ctx context.Context) error
+	Serve(ctx context.Context) error
+}
+
 func main() {
 
-	// ============================
-	// 1. 加载配置
-	// ============================
-	cfg := config.Load()
+	// 子命令：
+	//   app replay --from N --to M [--event X] [--dry-run]
+	//   app dlq list|requeue
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			os.Exit(runReplay(os.Args[2:]))
+		case "dlq":
+			os.Exit(runDLQ(os.Args[2:]))
+		}
+	}
+
+	os.Exit(run())
+}
+
+func run() int {
 
 	// ============================
-	// 2. 连接链节点（自动重连）
+	// 0. 退出信号
 	// ============================
-	client := chain.MustDial(cfg.RPCWS)
+	ctx, stopSignal := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignal()
 
 	// ============================
-	// 3. 合约地址
+	// 1. 加载配置
 	// ============================
-	addr := common.HexToAddress(cfg.Contract)
+	cfg := config.Load()
+
+	if len(cfg.Chains) == 0 {
+		log.Println("no chains configured")
+		return exitError
+	}
 
 	// ============================
-	// 4. 加载 ABI
+	// 2. 数据库（检查点、事件）
 	// ============================
-	abiBytes, err := os.ReadFile("abi.json")
-	if err != nil {
-		log.Fatal("read abi failed:", err)
+	if err := repo.InitDB(cfg.DB.DSN); err != nil {
+		log.Fatal("init db failed:", err)
 	}
 
-	parsedABI, err := abi.JSON(bytes.NewReader(abiBytes))
-	if err != nil {
-		log.Fatal("parse abi failed:", err)
+	// 通知 pledge-backend（可选）
+	channels := notify.Channels{
+		PoolRefresh: cfg.Notify.PoolRefreshChannel,
+		Governance:  cfg.Notify.GovernanceChannel,
+		Positions:   cfg.Notify.PositionsPrefix,
+	}
+	if err := notify.InitRedis(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, channels); err != nil {
+		log.Fatal("init redis failed:", err)
+	}
+	if cfg.Stream.Prefix != "" {
+		if err := notify.InitStream(cfg.Redis.Addr, cfg.Redis.Password, cfg.Stream.DB); err != nil {
+			log.Fatal("init redis stream failed:", err)
+		}
 	}
 
 	// ============================
-	// 5. 获取 DepositLend topic
+	// 3. 每条链连一次节点，每个合约一个 listener
 	// ============================
-	depositTopic := parsedABI.Events["DepositLend"].ID
+	var runners []runner
+	listeners, indexers := newListeners(ctx, cfg, true)
+	for _, l := range listeners {
+		runners = append(runners, l)
+	}
+	for _, x := range indexers {
+		runners = append(runners, x)
+	}
 
 	// ============================
-	// 6. 日志 channel（必须大buffer）
+	// 4. 运维接口：健康检查 + 指标
 	// ============================
-	logs := make(chan types.Log, 10000)
+	if cfg.HTTPAddr != "" {
+
+		srv := ops.NewServer(cfg.HTTPAddr)
+
+		srv.AddCheck("db", repo.DB.PingContext)
+		for _, r := range runners {
+			srv.AddCheck("rpc "+r.Name(), r.Ping)
+		}
+
+		go func() {
+			if err := srv.Run(ctx); err != nil {
+				log.Println("ops server failed:", err)
+			}
+		}()
+	}
 
 	// ============================
-	// 7. 启动 worker池
+	// 5. 启动，阻塞到收到退出信号
 	// ============================
-	worker.Start(cfg.WorkerNum, logs, func(v types.Log) {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		code = exitOK
+	)
 
-		// 只处理 DepositLend
-		if len(v.Topics) > 0 && v.Topics[0] == depositTopic {
+	// 事件 webhook 投递（可选）
+	if cfg.Webhook.Enabled {
+		sender := webhook.NewSender(webhook.Options{
+			Interval:    cfg.Webhook.Interval,
+			Timeout:     cfg.Webhook.Timeout,
+			BaseDelay:   cfg.Webhook.BaseDelay,
+			MaxDelay:    cfg.Webhook.MaxDelay,
+			MaxAttempts: cfg.Webhook.MaxAttempts,
+			MaxFailures: cfg.Webhook.MaxFailures,
+		})
 
-			// 调用解析函数
-			service.HandleDeposit(parsedABI, v)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sender.Run(ctx)
+		}()
+	}
 
-		}
-	})
+	for _, r := range runners {
+		wg.Add(1)
+		go func(r runner) {
+			defer wg.Done()
 
-	// ============================
-	// 8. 历史补扫（生产必须）
-	// ============================
-	latest, err := client.BlockNumber(context.Background())
-	if err != nil {
-		log.Fatal(err)
+			err := r.Serve(ctx)
+
+			c := exitOK
+			switch {
+			case err == nil:
+			case errors.Is(err, listener.ErrDrainTimeout):
+				c = exitDrainTimeout
+			default:
+				log.Println(r.Name(), err)
+				c = exitError
+			}
+
+			mu.Lock()
+			if c > code {
+				code = c
+			}
+			mu.Unlock()
+		}(r)
 	}
 
-	log.Println("scan history 0 ->", latest)
+	<-ctx.Done()
+
+	// 再收到一次信号直接按默认行为退出
+	stopSignal()
+	log.Println("shutting down...")
 
-	err = chain.ScanHistory(client, addr, 0, latest, logs)
-	if err != nil {
-		log.Fatal(err)
+	wg.Wait()
+
+	log.Println("exit", code)
+
+	return code
+}
+
+// calldata 为 true 时，配置了 calldata 的合约额外起一个按交易索引的任务，和日志监听共用这条链的节点连接
+func newListeners(ctx context.Context, cfg config.Config, calldata bool) ([]*listener.Listener, []*listener.CalldataIndexer) {
+
+	opts := listener.Options{
+		WorkerNum:       cfg.WorkerNum,
+		WorkerQueue:     cfg.WorkerQueue,
+		ShutdownTimeout: cfg.ShutdownTimeout,
+		Scan: chain.ScanOptions{
+			BatchSize: cfg.Scan.BatchSize,
+			MinBatch:  cfg.Scan.MinBatch,
+			MaxBatch:  cfg.Scan.MaxBatch,
+			MaxRetry:  cfg.Scan.MaxRetry,
+		},
+		DeadLetter: listener.DeadLetterOptions{
+			Interval:    cfg.DeadLetter.Interval,
+			BaseDelay:   cfg.DeadLetter.BaseDelay,
+			MaxDelay:    cfg.DeadLetter.MaxDelay,
+			MaxAttempts: cfg.DeadLetter.MaxAttempts,
+		},
+		Stream: listener.StreamOptions{
+			Prefix:   cfg.Stream.Prefix,
+			MaxLen:   cfg.Stream.MaxLen,
+			Interval: cfg.Stream.Interval,
+		},
 	}
 
-	// ============================
-	// 9. 实时订阅（生产必须）
-	// ============================
-	go chain.SubscribeLoop(client, addr, logs)
+	var listeners []*listener.Listener
+	var indexers []*listener.CalldataIndexer
+
+	for _, c := range cfg.Chains {
+
+		// 自动重连，websocket 不可用时用 HTTP 节点
+		client, fallback := chain.MustDial(c.RPCWS, c.RPCHTTP)
+
+		chainID, err := client.ChainID(ctx)
+		if err != nil {
+			log.Fatal("get chain id failed:", c.Name, err)
+		}
+
+		// 同一条链上的合约共用区块头缓存
+		headers := chain.NewHeaderCache(client, chainID.Uint64(), cfg.HeaderCacheSize)
+
+		for _, ct := range c.Contracts {
 
-	log.Println("listener started...")
+			l, err := listener.New(chainID.Uint64(), client, fallback, headers, c, ct, opts)
+			if err != nil {
+				log.Fatal(c.Name, "/", ct.Name, " ", err)
+			}
+
+			listeners = append(listeners, l)
+
+			if !calldata || !ct.Calldata {
+				continue
+			}
+
+			x, err := listener.NewCalldata(chainID.Uint64(), client, c, ct)
+			if errors.Is(err, listener.ErrNoStartBlock) {
+				// 没填部署区块只是不做 calldata 索引，日志监听照常
+				log.Print(c.Name, "/", ct.Name, " calldata indexing disabled: ", err)
+				continue
+			}
+			if err != nil {
+				log.Fatal(c.Name, "/", ct.Name, " ", err)
+			}
+
+			indexers = append(indexers, x)
+		}
+	}
 
-	// 阻塞
-	select {}
+	return listeners, indexers
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"contract-listener/internal/listener"
+	"contract-listener/internal/repo"
+	"contract-listener/pkg/config"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// 重放指定区块范围：app replay --from N --to M [--contract X] [--event X] [--dry-run]
+// 不订阅、不移动检查点，用于修复处理函数或新增事件类型后重建派生表
+func runReplay(args []string) int {
+
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+
+	from := fs.Uint64("from", 0, "first block (inclusive)")
+	to := fs.Uint64("to", 0, "last block (inclusive)")
+	contract := fs.String("contract", "", "contract name or chain/contract, required when more than one is configured")
+	event := fs.String("event", "", "only replay this event")
+	dryRun := fs.Bool("dry-run", false, "decode and print only, no db writes or handlers")
+
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *to == 0 {
+		fmt.Fprintln(os.Stderr, "replay: --to is required")
+		fs.Usage()
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	cfg := config.Load()
+
+	// dry-run 不写库，也就不需要数据库
+	if !*dryRun {
+		if err := repo.InitDB(cfg.DB.DSN); err != nil {
+			log.Println("init db failed:", err)
+			return exitError
+		}
+	}
+
+	listeners, _ := newListeners(ctx, cfg, false)
+	l, err := pickListener(listeners, *contract)
+	if err != nil {
+		log.Println("replay:", err)
+		return exitError
+	}
+
+	err = l.Replay(ctx, listener.ReplayOptions{
+		From:   *from,
+		To:     *to,
+		Event:  *event,
+		DryRun: *dryRun,
+	})
+	if err != nil {
+		log.Println("replay:", err)
+		if errors.Is(err, listener.ErrDrainTimeout) {
+			return exitDrainTimeout
+		}
+		return exitError
+	}
+
+	return exitOK
+}
+
+// 按合约名（或 链名/合约名）选 listener，只配置了一个时可以不指定
+func pickListener(listeners []*listener.Listener, name string) (*listener.Listener, error) {
+
+	if name == "" {
+		if len(listeners) == 1 {
+			return listeners[0], nil
+		}
+		return nil, fmt.Errorf("--contract is required, configured: %s", names(listeners))
+	}
+
+	var found []*listener.Listener
+	for _, l := range listeners {
+		if l.Name() == name || strings.HasSuffix(l.Name(), "/"+name) {
+			found = append(found, l)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("contract %s not configured, configured: %s", name, names(listeners))
+	case 1:
+		return found[0], nil
+	default:
+		return nil, fmt.Errorf("contract %s is ambiguous, use chain/contract: %s", name, names(found))
+	}
+}
+
+func names(listeners []*listener.Listener) string {
+
+	s := make([]string, len(listeners))
+	for i, l := range listeners {
+		s[i] = l.Name()
+	}
+
+	return strings.Join(s, ", ")
+}
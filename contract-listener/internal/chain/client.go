@@ -7,15 +7,35 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
+// websocket 连续失败多少次后改用 HTTP 节点
+const wsDialRetry = 3
+
 // 自动重连client
-func MustDial(url string) *ethclient.Client {
+// 配置了 httpURL 时返回的 fallback 为 HTTP 节点，websocket 连不上就直接用它（轮询模式）
+func MustDial(wsURL, httpURL string) (client, fallback *ethclient.Client) {
+
+	if httpURL != "" {
+		c, err := ethclient.Dial(httpURL)
+		if err != nil {
+			log.Println("dial http failed", err)
+		} else {
+			fallback = c
+		}
+	}
 
-	for {
-		c, err := ethclient.Dial(url)
+	for i := 1; ; i++ {
+
+		c, err := ethclient.Dial(wsURL)
 		if err == nil {
-			return c
+			return c, fallback
+		}
+
+		if fallback != nil && (wsURL == "" || i >= wsDialRetry) {
+			log.Println("websocket unreachable, use http polling", err)
+			return fallback, fallback
 		}
-		log.Println("dial retry")
+
+		log.Println("dial retry", err)
 		time.Sleep(time.Second * 3)
 	}
 }
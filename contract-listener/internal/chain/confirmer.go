@@ -0,0 +1,174 @@
+package chain
+
+import (
+	"context"
+	"contract-listener/internal/checkpoint"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// 缺口补拉时每次 FilterLogs 的区块数
+const gapBatch = 1000
+
+// 确认深度缓冲：日志所在区块被 depth 个区块覆盖后才放行给 worker
+type Confirmer struct {
+	addrs   []common.Address
+	depth   uint64
+	out     chan types.Log
+	tracker *checkpoint.Tracker
+	pending []types.Log // 按 (block, index) 排序
+
+	next  uint64 // 下一个待放行的区块，之前的都已派发
+	gapTo uint64 // [next, gapTo] 的日志没有从订阅拿全，确认后用 FilterLogs 补拉
+}
+
+// from 为第一个未派发的区块
+func NewConfirmer(addrs []common.Address, depth, from uint64, out chan types.Log, tracker *checkpoint.Tracker) *Confirmer {
+	return &Confirmer{
+		addrs:   addrs,
+		depth:   depth,
+		out:     out,
+		tracker: tracker,
+		next:    from,
+	}
+}
+
+func sameLog(a, b types.Log) bool {
+	return a.TxHash == b.TxHash && a.Index == b.Index && a.BlockHash == b.BlockHash
+}
+
+// 标记 head 及以前的区块需要补拉（订阅重建或轮询时调用）
+// 缺口里的日志不放进缓冲，等确认后直接从链上拉，避免拉到未确认分叉上的日志
+// 缓冲里已有的缺口内日志一并丢掉：它们可能来自断开前的分叉，和补拉到的主链日志区块哈希不同，去重不掉
+func (c *Confirmer) Gap(head uint64) {
+	if head < c.next || head <= c.gapTo {
+		return
+	}
+	c.gapTo = head
+
+	n := 0
+	for n < len(c.pending) && c.pending[n].BlockNumber <= head {
+		n++
+	}
+	c.pending = c.pending[n:]
+}
+
+// 收到一条日志（包括 removed）
+func (c *Confirmer) Push(l types.Log) {
+
+	if l.Removed {
+
+		// 还没确认：直接从缓冲里拿掉
+		for i, p := range c.pending {
+			if sameLog(p, l) {
+				c.pending = append(c.pending[:i], c.pending[i+1:]...)
+				return
+			}
+		}
+
+		// 还没放行的区块：不在缓冲里说明没派发过（缺口里丢掉的、或者本来就没收到），不用撤销
+		if l.BlockNumber >= c.next {
+			return
+		}
+
+		// 已经放行过：下发撤销事件，由下游补偿
+		c.tracker.Add(l.BlockNumber, "")
+		c.out <- l
+		return
+	}
+
+	// 已放行过的区块，或者会从缺口补拉的区块，不重复添加
+	if l.BlockNumber < c.next || l.BlockNumber <= c.gapTo {
+		return
+	}
+
+	c.insert(l)
+}
+
+func (c *Confirmer) insert(l types.Log) {
+
+	// 补拉和订阅可能重叠，缓冲里已有的不重复添加
+	for _, p := range c.pending {
+		if sameLog(p, l) {
+			return
+		}
+	}
+
+	i := sort.Search(len(c.pending), func(i int) bool {
+		p := c.pending[i]
+		return p.BlockNumber > l.BlockNumber ||
+			(p.BlockNumber == l.BlockNumber && p.Index > l.Index)
+	})
+
+	c.pending = append(c.pending, types.Log{})
+	copy(c.pending[i+1:], c.pending[i:])
+	c.pending[i] = l
+}
+
+// 新区块头：先补拉缺口里已确认的部分，再放行 head-depth 及以下的日志
+// 补拉失败时缺口保留，下一个区块头再试
+func (c *Confirmer) Head(ctx context.Context, client ethereum.LogFilterer, head uint64) error {
+
+	if head < c.depth {
+		return nil
+	}
+	safe := head - c.depth
+
+	if safe < c.next {
+		return nil
+	}
+
+	for c.next <= c.gapTo && c.next <= safe {
+
+		end := c.next + gapBatch - 1
+		if end > c.gapTo {
+			end = c.gapTo
+		}
+		if end > safe {
+			end = safe
+		}
+
+		logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(c.next),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: c.addrs,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, l := range logs {
+			if !l.Removed {
+				c.insert(l)
+			}
+		}
+
+		c.release(end)
+	}
+
+	c.release(safe)
+
+	return nil
+}
+
+// 放行 safe 及以下的日志
+func (c *Confirmer) release(safe uint64) {
+
+	n := 0
+	for n < len(c.pending) && c.pending[n].BlockNumber <= safe {
+		c.tracker.Add(c.pending[n].BlockNumber, c.pending[n].BlockHash.Hex())
+		c.out <- c.pending[n]
+		n++
+	}
+	c.pending = c.pending[n:]
+
+	if safe+1 > c.next {
+		c.next = safe + 1
+	}
+
+	c.tracker.Dispatched(safe)
+}
@@ -0,0 +1,228 @@
+package chain
+
+import (
+	"context"
+	"contract-listener/internal/checkpoint"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// 主链上的日志，按区块返回；err 不为空时 FilterLogs 直接失败
+type fakeFilterer struct {
+	logs []types.Log
+	err  error
+}
+
+func (f *fakeFilterer) FilterLogs(_ context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	var out []types.Log
+	for _, l := range f.logs {
+		if l.BlockNumber >= from && l.BlockNumber <= to {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeFilterer) SubscribeFilterLogs(context.Context, ethereum.FilterQuery, chan<- types.Log) (ethereum.Subscription, error) {
+	return nil, errors.New("not supported")
+}
+
+// fork 区分同一高度不同分叉上的区块
+func testLog(block uint64, index uint, fork byte) types.Log {
+	return types.Log{
+		BlockNumber: block,
+		Index:       index,
+		BlockHash:   common.Hash{fork, byte(block)},
+		TxHash:      common.Hash{0xee, byte(block), byte(index)},
+	}
+}
+
+func removed(l types.Log) types.Log {
+	l.Removed = true
+	return l
+}
+
+func describe(l types.Log) string {
+	s := fmt.Sprintf("%d/%d@%x", l.BlockNumber, l.Index, l.BlockHash[0])
+	if l.Removed {
+		s += " removed"
+	}
+	return s
+}
+
+func TestConfirmer(t *testing.T) {
+
+	type step struct {
+		push []types.Log
+		gap  uint64
+		head uint64 // 0 表示这一步不来新区块头
+		want []types.Log
+	}
+
+	tests := []struct {
+		name      string
+		from      uint64
+		depth     uint64
+		chain     []types.Log // FilterLogs 看到的主链
+		filterErr error
+		steps     []step
+		committed uint64
+	}{
+		{
+			name: "release after depth", from: 100, depth: 3,
+			steps: []step{
+				{push: []types.Log{testLog(101, 0, 1), testLog(103, 0, 1), testLog(101, 1, 1)}, head: 104,
+					want: []types.Log{testLog(101, 0, 1), testLog(101, 1, 1)}},
+				{head: 105},
+				{head: 106, want: []types.Log{testLog(103, 0, 1)}},
+			},
+			committed: 103,
+		},
+		{
+			name: "zero confirmations", from: 100, depth: 0,
+			steps: []step{
+				{push: []types.Log{testLog(100, 0, 1)}, head: 100, want: []types.Log{testLog(100, 0, 1)}},
+			},
+			committed: 100,
+		},
+		{
+			name: "duplicate log from subscription", from: 100, depth: 2,
+			steps: []step{
+				{push: []types.Log{testLog(101, 0, 1), testLog(101, 0, 1)}, head: 103, want: []types.Log{testLog(101, 0, 1)}},
+			},
+			committed: 101,
+		},
+		{
+			// 确认前被重组掉：从缓冲里拿掉，下游什么都收不到
+			name: "removed before release", from: 100, depth: 3,
+			steps: []step{
+				{push: []types.Log{testLog(101, 0, 1), removed(testLog(101, 0, 1)), testLog(101, 0, 2)}, head: 104,
+					want: []types.Log{testLog(101, 0, 2)}},
+			},
+			committed: 101,
+		},
+		{
+			// 放行后被重组掉：下发撤销
+			name: "removed after release", from: 100, depth: 1,
+			steps: []step{
+				{push: []types.Log{testLog(101, 0, 1)}, head: 102, want: []types.Log{testLog(101, 0, 1)}},
+				{push: []types.Log{removed(testLog(101, 0, 1))}, want: []types.Log{removed(testLog(101, 0, 1))}},
+			},
+			committed: 101,
+		},
+		{
+			// 还没放行的区块上、缓冲里没有的日志被撤销：从来没下发过，不能发撤销
+			name: "removed log never seen", from: 100, depth: 3,
+			steps: []step{
+				{push: []types.Log{removed(testLog(102, 0, 1))}, head: 106},
+			},
+			committed: 103,
+		},
+		{
+			// 断开前从分叉收到 101，重连后主链的 101 哈希不同；只能放行补拉到的主链日志
+			name: "gap drops buffered fork logs", from: 100, depth: 3,
+			chain: []types.Log{testLog(101, 0, 2)},
+			steps: []step{
+				{push: []types.Log{testLog(101, 0, 1)}},
+				{gap: 102, head: 105, want: []types.Log{testLog(101, 0, 2)}},
+			},
+			committed: 102,
+		},
+		{
+			// 分叉发生在缺口里：重连后收到分叉日志的 removed，不能下发撤销
+			name: "fork inside the gap", from: 100, depth: 3,
+			chain: []types.Log{testLog(101, 0, 2), testLog(102, 0, 2)},
+			steps: []step{
+				{push: []types.Log{testLog(101, 0, 1), testLog(102, 0, 1)}},
+				{gap: 102},
+				{push: []types.Log{removed(testLog(101, 0, 1)), removed(testLog(102, 0, 1)), testLog(102, 0, 2)}},
+				{head: 105, want: []types.Log{testLog(101, 0, 2), testLog(102, 0, 2)}},
+			},
+			committed: 102,
+		},
+		{
+			// 缺口之后的缓冲保留，和补拉的日志按区块顺序放行
+			name: "logs after the gap are kept", from: 100, depth: 2,
+			chain: []types.Log{testLog(101, 0, 1)},
+			steps: []step{
+				{push: []types.Log{testLog(101, 0, 1), testLog(103, 0, 1)}},
+				{gap: 102},
+				{head: 105, want: []types.Log{testLog(101, 0, 1), testLog(103, 0, 1)}},
+			},
+			committed: 103,
+		},
+		{
+			// 缺口只补拉已确认的部分，剩下的等后面的区块头
+			name: "gap filled as it confirms", from: 100, depth: 3,
+			chain: []types.Log{testLog(101, 0, 1), testLog(104, 0, 1)},
+			steps: []step{
+				{gap: 104, head: 104, want: []types.Log{testLog(101, 0, 1)}},
+				{push: []types.Log{testLog(104, 0, 1)}},
+				{head: 107, want: []types.Log{testLog(104, 0, 1)}},
+			},
+			committed: 104,
+		},
+		{
+			// 补拉失败：缺口保留，不放行缺口里的区块
+			name: "gap filter error", from: 100, depth: 1,
+			chain:     []types.Log{testLog(101, 0, 1)},
+			filterErr: errors.New("node down"),
+			steps: []step{
+				{gap: 101, head: 103},
+			},
+			committed: 99,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			out := make(chan types.Log, 100)
+			tracker := checkpoint.NewTracker(tt.from - 1)
+			c := NewConfirmer(nil, tt.depth, tt.from, out, tracker)
+			client := &fakeFilterer{logs: tt.chain, err: tt.filterErr}
+
+			for i, s := range tt.steps {
+				for _, l := range s.push {
+					c.Push(l)
+				}
+				if s.gap > 0 {
+					c.Gap(s.gap)
+				}
+				if s.head > 0 {
+					err := c.Head(context.Background(), client, s.head)
+					if !errors.Is(err, tt.filterErr) {
+						t.Fatalf("step %d: head err %v, want %v", i, err, tt.filterErr)
+					}
+				}
+
+				var got []string
+				for len(out) > 0 {
+					l := <-out
+					got = append(got, describe(l))
+					tracker.Done(l.BlockNumber)
+				}
+				var want []string
+				for _, l := range s.want {
+					want = append(want, describe(l))
+				}
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Fatalf("step %d: released %v, want %v", i, got, want)
+				}
+			}
+
+			if got := tracker.Committed(); got != tt.committed {
+				t.Errorf("committed %d, want %d", got, tt.committed)
+			}
+		})
+	}
+}
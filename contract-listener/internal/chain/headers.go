@@ -0,0 +1,100 @@
+package chain
+
+import (
+	"context"
+	"contract-listener/internal/metrics"
+	"fmt"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// 批量拉区块头时每个 JSON-RPC batch 的请求数
+const headerBatch = 100
+
+// 带区块头 LRU 缓存的节点客户端，同一条链上的各合约共用
+// 日志不带时间戳，入库和通知都要区块头；按区块哈希缓存，重组后的新区块哈希不同，不会读到旧的
+type HeaderCache struct {
+	*ethclient.Client
+	chainID string
+	cache   *lru.Cache[common.Hash, *types.Header]
+}
+
+func NewHeaderCache(client *ethclient.Client, chainID uint64, size int) *HeaderCache {
+	return &HeaderCache{
+		Client:  client,
+		chainID: strconv.FormatUint(chainID, 10),
+		cache:   lru.NewCache[common.Hash, *types.Header](size),
+	}
+}
+
+// 先查缓存，没有再请求节点
+func (c *HeaderCache) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+
+	if h, ok := c.cache.Get(hash); ok {
+		metrics.HeaderCache.WithLabelValues(c.chainID, "hit").Inc()
+		return h, nil
+	}
+	metrics.HeaderCache.WithLabelValues(c.chainID, "miss").Inc()
+
+	h, err := c.Client.HeaderByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Add(hash, h)
+
+	return h, nil
+}
+
+// 补扫时派发前预取日志所在区块的区块头，每 headerBatch 个区块一次 batch 请求
+// 失败不影响处理，处理时没命中缓存会再单独请求
+func (c *HeaderCache) Prefetch(ctx context.Context, logs []types.Log) error {
+
+	var hashes []common.Hash
+	seen := make(map[common.Hash]bool)
+
+	for _, l := range logs {
+		if seen[l.BlockHash] || c.cache.Contains(l.BlockHash) {
+			continue
+		}
+		seen[l.BlockHash] = true
+		hashes = append(hashes, l.BlockHash)
+	}
+
+	for start := 0; start < len(hashes); start += headerBatch {
+
+		end := min(start+headerBatch, len(hashes))
+
+		heads := make([]*types.Header, end-start)
+		batch := make([]rpc.BatchElem, end-start)
+		for i, hash := range hashes[start:end] {
+			batch[i] = rpc.BatchElem{
+				Method: "eth_getBlockByHash",
+				Args:   []any{hash, false},
+				Result: &heads[i],
+			}
+		}
+
+		if err := c.Client.Client().BatchCallContext(ctx, batch); err != nil {
+			return err
+		}
+
+		for i, b := range batch {
+			if b.Error != nil {
+				return b.Error
+			}
+			// 区块已被重组掉，节点查不到
+			if heads[i] == nil {
+				return fmt.Errorf("header %s: %w", hashes[start+i].Hex(), ethereum.NotFound)
+			}
+			c.cache.Add(hashes[start+i], heads[i])
+		}
+	}
+
+	return nil
+}
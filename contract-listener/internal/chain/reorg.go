@@ -0,0 +1,38 @@
+package chain
+
+import (
+	"context"
+	"contract-listener/internal/model"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// 检查保存的区块哈希是否还在主链上
+func IsCanonical(ctx context.Context, client *ethclient.Client, ref model.BlockRef) (bool, error) {
+
+	h, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(ref.Number))
+	if err != nil {
+		return false, err
+	}
+
+	return h.Hash().Hex() == ref.Hash, nil
+}
+
+// 找分叉点：refs 按区块号从高到低，返回第一个仍在主链上的区块
+// ok=false 表示保存的历史里全部被重组掉了
+func FindForkPoint(ctx context.Context, client *ethclient.Client, refs []model.BlockRef) (uint64, bool, error) {
+
+	for _, ref := range refs {
+
+		ok, err := IsCanonical(ctx, client, ref)
+		if err != nil {
+			return 0, false, err
+		}
+		if ok {
+			return ref.Number, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
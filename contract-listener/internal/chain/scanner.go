@@ -2,7 +2,11 @@ package chain
 
 import (
 	"context"
+	"contract-listener/internal/checkpoint"
+	"log"
 	"math/big"
+	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -10,27 +14,141 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
+// 补扫参数
+type ScanOptions struct {
+	BatchSize uint64 // 初始窗口（区块数）
+	MinBatch  uint64 // 节点报错时窗口最小缩到多少
+	MaxBatch  uint64 // 调用成功时窗口最大涨到多少
+	MaxRetry  int    // 同一窗口连续失败的最大重试次数
+
+	Topics [][]common.Hash // 可选，只拉指定事件（重放用）
+
+	Headers *HeaderCache // 可选，派发前批量预取区块头，避免处理时每条日志请求一次
+}
+
+// 各家节点对"范围太大/结果太多"的报错文案
+var rangeErrors = []string{
+	"query returned more than",
+	"exceed maximum block range",
+	"block range",
+	"range too large",
+	"range is too large",
+	"too wide",
+	"is limited to",
+	"response size exceeded",
+}
+
+func isRangeError(err error) bool {
+
+	msg := strings.ToLower(err.Error())
+
+	for _, s := range rangeErrors {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // 扫历史区块（生产必须）
+// 按窗口分段拉取：节点报范围错误就缩小窗口，成功就慢慢放大，其他错误退避重试
+// ctx 取消后在窗口边界停下：当前窗口的日志全部派发完才返回，检查点不会跳过半个窗口
 func ScanHistory(
+	ctx context.Context,
 	client *ethclient.Client,
-	addr common.Address,
+	addrs []common.Address,
 	from, to uint64,
 	out chan types.Log,
+	tracker *checkpoint.Tracker,
+	opts ScanOptions,
 ) error {
 
-	q := ethereum.FilterQuery{
-		FromBlock: big.NewInt(int64(from)),
-		ToBlock:   big.NewInt(int64(to)),
-		Addresses: []common.Address{addr},
-	}
+	size := opts.BatchSize
+	retry := 0
+	total := to - from + 1
 
-	logs, err := client.FilterLogs(context.Background(), q)
-	if err != nil {
-		return err
-	}
+	for start := from; start <= to; {
+
+		if err := ctx.Err(); err != nil {
+			log.Println("scan stopped at", start)
+			return err
+		}
+
+		end := start + size - 1
+		if end > to {
+			end = to
+		}
+
+		q := ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: addrs,
+			Topics:    opts.Topics,
+		}
+
+		logs, err := client.FilterLogs(ctx, q)
+		if err != nil {
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			// 窗口太大：减半后立即重试
+			if isRangeError(err) && size > opts.MinBatch {
+				size /= 2
+				if size < opts.MinBatch {
+					size = opts.MinBatch
+				}
+				log.Println("scan shrink window", start, "->", end, "size", size, err)
+				continue
+			}
+
+			retry++
+			if retry > opts.MaxRetry {
+				return err
+			}
+
+			wait := time.Second << (retry - 1)
+			if wait > 30*time.Second {
+				wait = 30 * time.Second
+			}
+			log.Println("scan retry", retry, start, "->", end, "after", wait, err)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+
+		retry = 0
+
+		if opts.Headers != nil {
+			if err := opts.Headers.Prefetch(ctx, logs); err != nil {
+				log.Println("prefetch headers failed", start, "->", end, err)
+			}
+		}
+
+		for _, l := range logs {
+			tracker.Add(l.BlockNumber, l.BlockHash.Hex())
+			out <- l
+		}
+
+		tracker.Dispatched(end)
+
+		log.Printf("scan progress %d -> %d, %d logs, %.2f%%\n",
+			start, end, len(logs), float64(end-from+1)*100/float64(total))
+
+		start = end + 1
 
-	for _, l := range logs {
-		out <- l
+		// 成功后窗口放大 1/4
+		if size < opts.MaxBatch {
+			size += size/4 + 1
+			if size > opts.MaxBatch {
+				size = opts.MaxBatch
+			}
+		}
 	}
 
 	return nil
@@ -2,6 +2,8 @@ package chain
 
 import (
 	"context"
+	"contract-listener/internal/checkpoint"
+	"errors"
 	"log"
 	"time"
 
@@ -9,40 +11,167 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	pollInterval = 3 * time.Second // 轮询间隔（BSC 出块 3 秒）
+	wsRetryAfter = time.Minute     // 退回轮询后多久再尝试 websocket
+	maxBackoff   = 30 * time.Second
 )
 
 // 实时订阅（自动重连）
+// from 为补扫之后第一个未派发的区块；日志经过确认深度缓冲后才放行
+// 每次订阅重建时，断开期间的区块作为缺口，确认后用 FilterLogs 补拉，与订阅重叠的部分去重
+// websocket 订阅失败且配置了 HTTP 节点 fallback 时，临时改为轮询
+// ctx 取消后退订并返回，确认缓冲里还没放行的日志丢弃，重启后从检查点重新拉取
+// onReconnect 在每次订阅断开重连时调用（统计用，可为 nil）
 func SubscribeLoop(
+	ctx context.Context,
 	client *ethclient.Client,
-	addr common.Address,
+	fallback *ethclient.Client,
+	addrs []common.Address,
+	from uint64,
+	depth uint64,
 	out chan types.Log,
+	tracker *checkpoint.Tracker,
+	onReconnect func(),
 ) {
 
-	for {
+	confirmer := NewConfirmer(addrs, depth, from, out, tracker)
+	backoff := time.Second
+
+	for ctx.Err() == nil {
+
+		established, err := live(ctx, client, confirmer)
+		if ctx.Err() != nil {
+			break
+		}
 
-		ch := make(chan types.Log)
+		// HTTP 节点不支持订阅，只能一直轮询
+		if errors.Is(err, rpc.ErrNotificationsUnsupported) {
+			log.Println("subscription unsupported, polling", err)
+			poll(ctx, client, confirmer, 0)
+			break
+		}
 
-		q := ethereum.FilterQuery{
-			Addresses: []common.Address{addr},
+		if established {
+			backoff = time.Second
+		}
+		log.Println("subscription dropped, next block", confirmer.next, err)
+		if onReconnect != nil {
+			onReconnect()
 		}
 
-		sub, err := client.SubscribeFilterLogs(context.Background(), q, ch)
-		if err != nil {
-			time.Sleep(time.Second)
+		if fallback != nil {
+			log.Println("fallback to http polling for", wsRetryAfter)
+			poll(ctx, fallback, confirmer, wsRetryAfter)
 			continue
 		}
 
-		for {
-			select {
+		sleep(ctx, backoff)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	log.Println("subscription stopped")
+}
+
+// 一次 websocket 订阅，出错或 ctx 取消时返回
+// established 表示订阅建立成功过（用于重置退避）
+func live(ctx context.Context, client *ethclient.Client, c *Confirmer) (bool, error) {
+
+	ch := make(chan types.Log)
+	heads := make(chan *types.Header)
+
+	q := ethereum.FilterQuery{
+		Addresses: c.addrs,
+	}
+
+	sub, err := client.SubscribeFilterLogs(ctx, q, ch)
+	if err != nil {
+		return false, err
+	}
+	defer sub.Unsubscribe()
+
+	headSub, err := client.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		return false, err
+	}
+	defer headSub.Unsubscribe()
+
+	// 订阅建立前的区块（断开期间 + 还没确认的）都当作缺口补拉
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return false, err
+	}
+	c.Gap(head)
+
+	if err := c.Head(ctx, client, head); err != nil {
+		log.Println("backfill gap failed", err)
+	}
+
+	for {
+		select {
 
-			case err := <-sub.Err():
-				log.Println("sub error", err)
-				time.Sleep(time.Second)
-				break
+		case <-ctx.Done():
+			return true, ctx.Err()
 
-			case v := <-ch:
-				out <- v
+		case err := <-sub.Err():
+			return true, err
+
+		case err := <-headSub.Err():
+			return true, err
+
+		case h := <-heads:
+			if err := c.Head(ctx, client, h.Number.Uint64()); err != nil {
+				log.Println("backfill gap failed", err)
 			}
+
+		case v := <-ch:
+			// removed=true 表示该日志所在区块被重组掉
+			c.Push(v)
 		}
 	}
 }
+
+// HTTP 轮询：每轮把最新区块之前的都当作缺口，只拉已确认的部分
+// d 为轮询时长，0 表示一直轮询到 ctx 取消
+func poll(ctx context.Context, client *ethclient.Client, c *Confirmer, d time.Duration) {
+
+	start := time.Now()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+
+		head, err := client.BlockNumber(ctx)
+		if err == nil {
+			c.Gap(head)
+			err = c.Head(ctx, client, head)
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Println("poll failed", err)
+		}
+
+		if d > 0 && time.Since(start) >= d {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 可被 ctx 打断的 sleep
+func sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,125 @@
+package checkpoint
+
+import (
+	"contract-listener/internal/model"
+	"sync"
+)
+
+// Tracker 记录派发出去、还没处理完的日志，
+// 算出"该高度及以下的日志全部处理完成"的最高区块
+type Tracker struct {
+	mu         sync.Mutex
+	pending    map[uint64]int    // 区块 -> 未处理完的日志数
+	hashes     map[uint64]string // 区块 -> 派发的日志里带的区块哈希，保存检查点时核对是否还在主链上
+	dispatched uint64            // 该高度及以下的日志都已派发
+	saved      uint64            // 已持久化的检查点
+}
+
+// last 为上次持久化的检查点
+func NewTracker(last uint64) *Tracker {
+	return &Tracker{
+		pending:    make(map[uint64]int),
+		hashes:     make(map[uint64]string),
+		dispatched: last,
+		saved:      last,
+	}
+}
+
+// 日志派发前调用，hash 为日志所在区块的哈希；撤销（removed）日志传空，不参与核对
+func (t *Tracker) Add(block uint64, hash string) {
+	t.mu.Lock()
+	t.pending[block]++
+	if hash != "" {
+		t.hashes[block] = hash
+	}
+	t.mu.Unlock()
+}
+
+// 日志处理完成后调用
+func (t *Tracker) Done(block uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pending[block]--
+	if t.pending[block] <= 0 {
+		delete(t.pending, block)
+	}
+}
+
+// 声明 block 及以下的日志已全部派发
+func (t *Tracker) Dispatched(block uint64) {
+	t.mu.Lock()
+	if block > t.dispatched {
+		t.dispatched = block
+	}
+	t.mu.Unlock()
+}
+
+// 可安全持久化的最高区块
+func (t *Tracker) Committed() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.committed()
+}
+
+// 最近一次持久化的检查点
+func (t *Tracker) Saved() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.saved
+}
+
+func (t *Tracker) committed() uint64 {
+
+	c := t.dispatched
+
+	for b := range t.pending {
+		if b == 0 {
+			return 0
+		}
+		if b-1 < c {
+			c = b - 1
+		}
+	}
+
+	return c
+}
+
+// 检查点有前进时调用 save 持久化
+// seen 为上次保存之后、block 及以下处理过的日志里区块号最高的一个（没有日志时为零值），
+// 区块哈希会承诺之前的所有区块，save 只要核对它还在主链上，就能确认这段日志没有落在分叉链上
+func (t *Tracker) Flush(save func(block uint64, seen model.BlockRef) error) error {
+
+	t.mu.Lock()
+	c := t.committed()
+	if c <= t.saved {
+		t.mu.Unlock()
+		return nil
+	}
+	var seen model.BlockRef
+	for b, h := range t.hashes {
+		if b <= c && b > seen.Number {
+			seen = model.BlockRef{Number: b, Hash: h}
+		}
+	}
+	t.mu.Unlock()
+
+	if err := save(c, seen); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	if c > t.saved {
+		t.saved = c
+	}
+	for b := range t.hashes {
+		if b <= t.saved {
+			delete(t.hashes, b)
+		}
+	}
+	t.mu.Unlock()
+
+	return nil
+}
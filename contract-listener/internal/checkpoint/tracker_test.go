@@ -0,0 +1,193 @@
+package checkpoint
+
+import (
+	"contract-listener/internal/model"
+	"errors"
+	"testing"
+)
+
+type saveCall struct {
+	block uint64
+	seen  model.BlockRef
+}
+
+// 每一步：派发 / 处理完成 / 声明派发进度 / 保存，检查保存时的区块和核对用的日志区块
+func TestTrackerFlush(t *testing.T) {
+
+	type step struct {
+		add        []model.BlockRef // 派发的日志
+		done       []uint64         // 处理完成的日志所在区块
+		dispatched uint64
+		saveErr    error
+		want       *saveCall // nil 表示不应保存
+	}
+
+	tests := []struct {
+		name  string
+		last  uint64
+		steps []step
+		saved uint64
+	}{
+		{
+			name: "nothing dispatched",
+			last: 100,
+			steps: []step{
+				{},
+			},
+			saved: 100,
+		},
+		{
+			name: "empty blocks advance to dispatched",
+			last: 100,
+			steps: []step{
+				{dispatched: 110, want: &saveCall{block: 110}},
+			},
+			saved: 110,
+		},
+		{
+			name: "held below the lowest unfinished block",
+			last: 100,
+			steps: []step{
+				{
+					add:        []model.BlockRef{{Number: 103, Hash: "0x103"}, {Number: 105, Hash: "0x105"}, {Number: 108, Hash: "0x108"}},
+					done:       []uint64{103, 108},
+					dispatched: 110,
+					want:       &saveCall{block: 104, seen: model.BlockRef{Number: 103, Hash: "0x103"}},
+				},
+				{
+					done: []uint64{105},
+					want: &saveCall{block: 110, seen: model.BlockRef{Number: 108, Hash: "0x108"}},
+				},
+			},
+			saved: 110,
+		},
+		{
+			name: "out of order completion",
+			last: 100,
+			steps: []step{
+				{
+					add:        []model.BlockRef{{Number: 101, Hash: "0x101"}, {Number: 102, Hash: "0x102"}},
+					done:       []uint64{102},
+					dispatched: 102,
+				},
+			},
+			saved: 100,
+		},
+		{
+			name: "several logs in one block",
+			last: 100,
+			steps: []step{
+				{
+					add:        []model.BlockRef{{Number: 101, Hash: "0x101"}, {Number: 101, Hash: "0x101"}},
+					done:       []uint64{101},
+					dispatched: 101,
+				},
+				{
+					done: []uint64{101},
+					want: &saveCall{block: 101, seen: model.BlockRef{Number: 101, Hash: "0x101"}},
+				},
+			},
+			saved: 101,
+		},
+		{
+			// 撤销日志不带哈希，不参与核对
+			name: "removed logs are not checked",
+			last: 100,
+			steps: []step{
+				{
+					add:        []model.BlockRef{{Number: 102, Hash: "0x102"}, {Number: 104}},
+					done:       []uint64{102, 104},
+					dispatched: 105,
+					want:       &saveCall{block: 105, seen: model.BlockRef{Number: 102, Hash: "0x102"}},
+				},
+			},
+			saved: 105,
+		},
+		{
+			// 已保存的区块不再拿来核对
+			name: "seen only since last save",
+			last: 100,
+			steps: []step{
+				{
+					add:        []model.BlockRef{{Number: 102, Hash: "0x102"}},
+					done:       []uint64{102},
+					dispatched: 103,
+					want:       &saveCall{block: 103, seen: model.BlockRef{Number: 102, Hash: "0x102"}},
+				},
+				{
+					dispatched: 106,
+					want:       &saveCall{block: 106},
+				},
+			},
+			saved: 106,
+		},
+		{
+			// 保存失败不前进，下次带着同样的核对区块重试
+			name: "save error keeps progress",
+			last: 100,
+			steps: []step{
+				{
+					add:        []model.BlockRef{{Number: 102, Hash: "0x102"}},
+					done:       []uint64{102},
+					dispatched: 103,
+					saveErr:    errors.New("forked"),
+					want:       &saveCall{block: 103, seen: model.BlockRef{Number: 102, Hash: "0x102"}},
+				},
+				{
+					want: &saveCall{block: 103, seen: model.BlockRef{Number: 102, Hash: "0x102"}},
+				},
+			},
+			saved: 103,
+		},
+		{
+			name: "dispatched never goes back",
+			last: 100,
+			steps: []step{
+				{dispatched: 110, want: &saveCall{block: 110}},
+				{dispatched: 105},
+			},
+			saved: 110,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			tr := NewTracker(tt.last)
+
+			for i, s := range tt.steps {
+				for _, r := range s.add {
+					tr.Add(r.Number, r.Hash)
+				}
+				for _, b := range s.done {
+					tr.Done(b)
+				}
+				if s.dispatched > 0 {
+					tr.Dispatched(s.dispatched)
+				}
+
+				var got *saveCall
+				err := tr.Flush(func(b uint64, seen model.BlockRef) error {
+					got = &saveCall{block: b, seen: seen}
+					return s.saveErr
+				})
+				if !errors.Is(err, s.saveErr) {
+					t.Fatalf("step %d: flush err %v, want %v", i, err, s.saveErr)
+				}
+
+				switch {
+				case got == nil && s.want != nil:
+					t.Fatalf("step %d: not saved, want %+v", i, *s.want)
+				case got != nil && s.want == nil:
+					t.Fatalf("step %d: saved %+v, want nothing", i, *got)
+				case got != nil && *got != *s.want:
+					t.Fatalf("step %d: saved %+v, want %+v", i, *got, *s.want)
+				}
+			}
+
+			if got := tr.Saved(); got != tt.saved {
+				t.Errorf("saved %d, want %d", got, tt.saved)
+			}
+		})
+	}
+}
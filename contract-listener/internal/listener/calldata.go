@@ -0,0 +1,286 @@
+package listener
+
+import (
+	"context"
+	"contract-listener/internal/chain"
+	"contract-listener/internal/metrics"
+	"contract-listener/internal/repo"
+	"contract-listener/internal/service"
+	"contract-listener/pkg/config"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	calldataPoll      = 3 * time.Second // 追上最新确认区块后的轮询间隔
+	calldataSaveEvery = 100             // 没有匹配交易时每隔多少个区块保存一次检查点
+)
+
+// 按交易 calldata 索引不发事件的合约方法（BscPledgeOracle 喂价、PledgePool.setPause）
+// 逐个扫描已确认区块里直接发给合约的交易，只处理执行成功的
+// 经其他合约内部调用的不在交易 calldata 里，索引不到
+type CalldataIndexer struct {
+	name          string
+	labels        []string
+	chainID       uint64
+	client        *ethclient.Client
+	addr          common.Address
+	key           string // 检查点键，和同一合约的日志监听分开
+	abi           abi.ABI
+	handlers      map[string]func(service.Call) error // 方法名 -> 处理函数
+	startBlock    uint64
+	confirmations uint64
+}
+
+func NewCalldata(chainID uint64, client *ethclient.Client, c config.Chain, ct config.Contract) (*CalldataIndexer, error) {
+
+	handlers := service.CallHandlers(ct.Name)
+	if len(handlers) == 0 {
+		return nil, fmt.Errorf("contract %s has no calldata handlers", ct.Name)
+	}
+
+	// 逐块拉取交易，从 0 开始等于从创世块扫全链
+	if ct.StartBlock == 0 {
+		return nil, fmt.Errorf("contract %s: %w", ct.Name, ErrNoStartBlock)
+	}
+
+	parsedABI, err := loadABI(ct.ABI)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CalldataIndexer{
+		name:          c.Name + "/" + ct.Name + ":calldata",
+		labels:        []string{strconv.FormatUint(chainID, 10), ct.Name + ":calldata"},
+		chainID:       chainID,
+		client:        client,
+		addr:          common.HexToAddress(ct.Address),
+		key:           strings.ToLower(ct.Name) + ":calldata",
+		abi:           parsedABI,
+		handlers:      handlers,
+		startBlock:    ct.StartBlock,
+		confirmations: *c.Confirmations,
+	}, nil
+}
+
+func (x *CalldataIndexer) Name() string {
+	return x.name
+}
+
+func (x *CalldataIndexer) Ping(ctx context.Context) error {
+	_, err := x.client.BlockNumber(ctx)
+	return err
+}
+
+// 扫描直到 ctx 取消或出错
+func (x *CalldataIndexer) Run(ctx context.Context) error {
+
+	if err := x.rewindOnReorg(ctx); err != nil {
+		return fmt.Errorf("check reorg failed: %w", err)
+	}
+
+	next := x.startBlock
+	last, ok, err := repo.GetLastBlock(x.chainID, x.key)
+	if err != nil {
+		return fmt.Errorf("load checkpoint failed: %w", err)
+	}
+	if ok && last+1 > next {
+		next = last + 1
+	}
+
+	log.Println(x.name, "calldata indexer started at", next)
+
+	for {
+
+		head, err := x.client.BlockNumber(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return x.stop(next)
+			}
+			return fmt.Errorf("get latest block failed: %w", err)
+		}
+		metrics.HeadBlock.WithLabelValues(x.labels...).Set(float64(head))
+
+		// 只处理已确认的区块，不用处理重组撤销
+		for ; head >= x.confirmations && next <= head-x.confirmations && ctx.Err() == nil; next++ {
+
+			block, matched, err := x.scanBlock(ctx, next)
+			if err != nil {
+				if ctx.Err() != nil {
+					break
+				}
+				return fmt.Errorf("scan block %d failed: %w", next, err)
+			}
+
+			if matched > 0 || next%calldataSaveEvery == 0 {
+				if err := repo.SaveLastBlock(x.chainID, x.key, next, block.Hash().Hex()); err != nil {
+					return fmt.Errorf("save checkpoint failed: %w", err)
+				}
+			}
+
+			metrics.ProcessedBlock.WithLabelValues(x.labels...).Set(float64(next))
+			metrics.Lag.WithLabelValues(x.labels...).Set(float64(head - next))
+		}
+
+		select {
+		case <-ctx.Done():
+			return x.stop(next)
+		case <-time.After(calldataPoll):
+		}
+	}
+}
+
+// 处理一个区块里发给合约的交易，返回处理的调用数
+func (x *CalldataIndexer) scanBlock(ctx context.Context, number uint64) (*types.Block, int, error) {
+
+	block, err := x.client.BlockByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	signer := types.LatestSignerForChainID(new(big.Int).SetUint64(x.chainID))
+	matched := 0
+
+	for i, tx := range block.Transactions() {
+
+		if tx.To() == nil || *tx.To() != x.addr || len(tx.Data()) < 4 {
+			continue
+		}
+
+		m, err := x.abi.MethodById(tx.Data()[:4])
+		if err != nil {
+			continue
+		}
+
+		h, ok := x.handlers[m.Name]
+		if !ok {
+			continue
+		}
+
+		args, err := m.Inputs.Unpack(tx.Data()[4:])
+		if err != nil {
+			log.Println(x.name, "unpack", m.Name, "failed, tx", tx.Hash().Hex(), err)
+			continue
+		}
+
+		// 失败的交易（多签没通过等）不改合约状态
+		receipt, err := x.client.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			return nil, 0, err
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			log.Println(x.name, m.Name, "reverted, tx", tx.Hash().Hex())
+			continue
+		}
+
+		from, err := types.Sender(signer, tx)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		err = h(service.Call{
+			ChainID:   x.chainID,
+			Contract:  x.addr,
+			TxHash:    tx.Hash(),
+			TxIndex:   uint(i),
+			From:      from,
+			Method:    m,
+			Args:      args,
+			Block:     number,
+			BlockTime: time.Unix(int64(block.Time()), 0).UTC(),
+		})
+		if err != nil {
+			return nil, 0, fmt.Errorf("handle %s tx %s failed: %w", m.Name, tx.Hash().Hex(), err)
+		}
+		matched++
+	}
+
+	return block, matched, nil
+}
+
+// 停机前保存已扫描的进度，next 为下一个待扫描的区块
+func (x *CalldataIndexer) stop(next uint64) error {
+
+	if next <= x.startBlock {
+		return nil
+	}
+	number := next - 1
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	h, err := x.client.HeaderByNumber(ctx, new(big.Int).SetUint64(number))
+	if err != nil {
+		return fmt.Errorf("save checkpoint failed: %w", err)
+	}
+
+	if err := repo.SaveLastBlock(x.chainID, x.key, number, h.Hash().Hex()); err != nil {
+		return fmt.Errorf("save checkpoint failed: %w", err)
+	}
+
+	log.Println(x.name, "checkpoint saved at", number)
+	return nil
+}
+
+// 检查点所在区块被重组掉（超过确认深度的深重组）时，删除分叉点之后的记录并回退
+func (x *CalldataIndexer) rewindOnReorg(ctx context.Context) error {
+
+	refs, err := repo.GetBlockHashes(x.chainID, x.key)
+	if err != nil || len(refs) == 0 {
+		return err
+	}
+
+	ok, err := chain.IsCanonical(ctx, x.client, refs[0])
+	if err != nil || ok {
+		return err
+	}
+
+	fork, found, err := chain.FindForkPoint(ctx, x.client, refs[1:])
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("reorg deeper than saved history, checkpoint %d", refs[0].Number)
+	}
+
+	log.Println(x.name, "reorg detected at", refs[0].Number, "rewind checkpoint to", fork)
+
+	if err := repo.DeleteCallsAfter(x.chainID, x.addr.Hex(), fork); err != nil {
+		return err
+	}
+
+	return repo.RewindLastBlock(x.chainID, x.key, fork)
+}
+
+// 出错后重启，ctx 取消后返回
+func (x *CalldataIndexer) Serve(ctx context.Context) error {
+
+	for {
+
+		err := x.Run(ctx)
+		if ctx.Err() != nil {
+			return err
+		}
+		if err == nil {
+			err = errors.New("stopped")
+		}
+
+		log.Println(x.name, "stopped:", err, "restart in", restartDelay)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(restartDelay):
+		}
+	}
+}
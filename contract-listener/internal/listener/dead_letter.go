@@ -0,0 +1,119 @@
+package listener
+
+import (
+	"context"
+	"contract-listener/internal/metrics"
+	"contract-listener/internal/model"
+	"contract-listener/internal/repo"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// 每轮最多重试多少条
+const deadLetterBatch = 100
+
+// 死信重试参数
+type DeadLetterOptions struct {
+	Interval    time.Duration
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	MaxAttempts int
+}
+
+// 第 attempts 次失败后的等待时间：BaseDelay * 2^(attempts-1)，不超过 MaxDelay
+func (o DeadLetterOptions) backoff(attempts int) time.Duration {
+
+	d := o.BaseDelay
+	for i := 1; i < attempts && d < o.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > o.MaxDelay {
+		d = o.MaxDelay
+	}
+
+	return d
+}
+
+// 解码或处理失败的日志写入死信队列（原始日志 + 错误 + 次数）
+func (l *Listener) deadLetter(v types.Log, cause error) error {
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	event := l.registry.EventName(v)
+	metrics.DeadLetters.WithLabelValues(l.labels[0], l.labels[1], event).Inc()
+
+	return repo.SaveDeadLetter(model.DeadLetter{
+		ChainID:     l.chainID,
+		Contract:    l.contract,
+		TxHash:      v.TxHash.Hex(),
+		LogIndex:    v.Index,
+		Block:       v.BlockNumber,
+		Event:       event,
+		Log:         raw,
+		Error:       cause.Error(),
+		NextRetryAt: time.Now().Add(l.opts.DeadLetter.backoff(1)),
+	})
+}
+
+// 定时重试到期的死信，直到 ctx 取消
+// 重试不经过 worker 分区，和同一用户后续事件的相对顺序不保证
+func (l *Listener) retryDeadLetters(ctx context.Context) {
+
+	ticker := time.NewTicker(l.opts.DeadLetter.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		list, err := repo.DueDeadLetters(l.chainID, l.contract, deadLetterBatch)
+		if err != nil {
+			log.Println(l.name, "load dead letters failed:", err)
+			continue
+		}
+
+		for _, d := range list {
+			if ctx.Err() != nil {
+				return
+			}
+			l.retry(d)
+		}
+	}
+}
+
+func (l *Listener) retry(d model.DeadLetter) {
+
+	var v types.Log
+	err := json.Unmarshal(d.Log, &v)
+	if err == nil {
+		err = l.registry.Retry(v)
+	}
+
+	if err == nil {
+		log.Println(l.name, "dead letter", d.ID, "retried ok", d.Event, d.TxHash, d.LogIndex)
+		if err := repo.MarkDeadLetterDone(d.ID); err != nil {
+			log.Println(l.name, "mark dead letter done failed:", err)
+		}
+		return
+	}
+
+	attempts := d.Attempts + 1
+	exhausted := attempts >= l.opts.DeadLetter.MaxAttempts
+	if exhausted {
+		log.Println(l.name, "dead letter", d.ID, "gave up after", attempts, "attempts:", err)
+	}
+
+	next := time.Now().Add(l.opts.DeadLetter.backoff(attempts))
+	if err := repo.RetryDeadLetterFailed(d.ID, err.Error(), next, exhausted); err != nil {
+		log.Println(l.name, "update dead letter failed:", err)
+	}
+}
@@ -0,0 +1,199 @@
+package listener
+
+import (
+	"context"
+	"contract-listener/internal/model"
+	"contract-listener/internal/repo"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// 多久检查一次有没有新池子
+const discoverInterval = time.Minute
+
+// 从 PledgePool 的池子信息里发现 sp/jp DebtToken
+// 池子创建后 sp/jp 地址不再变化，已读过的 pid 缓存下来，之后每次只查 poolLength 和新增的池子
+type discoverer struct {
+	pool common.Address
+	abi  abi.ABI
+
+	mu     sync.Mutex
+	known  uint64 // 已读过的池子数
+	seen   map[common.Address]bool
+	cached []model.DebtToken
+}
+
+// 按 pid 顺序返回全部池子的 DebtToken，同一代币只保留第一次出现
+func (d *discoverer) tokens(ctx context.Context, client *ethclient.Client, chainID uint64) ([]model.DebtToken, error) {
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	out, err := d.call(ctx, client, "poolLength")
+	if err != nil {
+		return nil, err
+	}
+	n, ok := out[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected poolLength type %T", out[0])
+	}
+
+	if d.seen == nil {
+		d.seen = make(map[common.Address]bool)
+	}
+
+	for pid := d.known; pid < n.Uint64(); pid++ {
+
+		data, err := d.abi.Pack("poolBaseInfo", new(big.Int).SetUint64(pid))
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := client.CallContract(ctx, ethereum.CallMsg{To: &d.pool, Data: data}, nil)
+		if err != nil {
+			return nil, fmt.Errorf("call poolBaseInfo(%d) failed: %w", pid, err)
+		}
+
+		info := make(map[string]any)
+		if err := d.abi.UnpackIntoMap(info, "poolBaseInfo", res); err != nil {
+			return nil, err
+		}
+
+		// 一个池子的两个代币一起缓存，中途出错下次从这个 pid 重新读
+		var found []model.DebtToken
+		for _, side := range []struct{ field, side string }{
+			{"spCoin", model.DebtSideSP},
+			{"jpCoin", model.DebtSideJP},
+		} {
+			token, _ := info[side.field].(common.Address)
+			if token == (common.Address{}) {
+				continue
+			}
+			if d.seen[token] {
+				log.Println("debt token", token.Hex(), "reused by pool", pid, "kept on first pool")
+				continue
+			}
+			d.seen[token] = true
+
+			found = append(found, model.DebtToken{
+				ChainID: chainID,
+				Token:   token.Hex(),
+				Pool:    d.pool.Hex(),
+				PoolID:  pid,
+				Side:    side.side,
+			})
+		}
+
+		d.cached = append(d.cached, found...)
+		d.known = pid + 1
+	}
+
+	return append([]model.DebtToken(nil), d.cached...), nil
+}
+
+func (d *discoverer) call(ctx context.Context, client *ethclient.Client, method string) ([]any, error) {
+
+	data, err := d.abi.Pack(method)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.CallContract(ctx, ethereum.CallMsg{To: &d.pool, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("call %s failed: %w", method, err)
+	}
+
+	return d.abi.Unpack(method, res)
+}
+
+// 发现并登记代币，更新监听地址；返回还没从起始区块补扫过的代币
+func (l *Listener) syncTokens(ctx context.Context) ([]common.Address, error) {
+
+	tokens, err := l.discover.tokens(ctx, l.client, l.chainID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tokens {
+		if err := repo.SaveDebtToken(t); err != nil {
+			return nil, err
+		}
+	}
+
+	known, err := repo.GetDebtTokens(l.chainID, l.discover.pool.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]common.Address, 0, len(known))
+	var unsynced []common.Address
+	for token, synced := range known {
+		a := common.HexToAddress(token)
+		addrs = append(addrs, a)
+		if !synced {
+			unsynced = append(unsynced, a)
+		}
+	}
+
+	l.addrs = addrs
+	return unsynced, nil
+}
+
+// 链上有没有还没监听的代币（运行中定时检查）
+func (l *Listener) tokensChanged(ctx context.Context) (bool, error) {
+
+	tokens, err := l.discover.tokens(ctx, l.client, l.chainID)
+	if err != nil {
+		return false, err
+	}
+
+	watching := make(map[string]bool, len(l.addrs))
+	for _, a := range l.addrs {
+		watching[strings.ToLower(a.Hex())] = true
+	}
+
+	for _, t := range tokens {
+		if !watching[strings.ToLower(t.Token)] {
+			log.Println(l.name, "new debt token", t.Token, "pool", t.PoolID, t.Side)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// 新发现的代币：把 from 之前的区块补扫一遍，之后和其他代币一起从 from 继续
+func (l *Listener) backfillTokens(ctx context.Context, tokens []common.Address, from uint64) error {
+
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	if from > l.startBlock {
+		log.Println(l.name, "backfill", len(tokens), "new tokens", l.startBlock, "->", from-1)
+
+		failed, err := l.scanRange(ctx, tokens, l.startBlock, from-1, l.opts.Scan, false)
+		if err != nil {
+			return err
+		}
+		if failed > 0 {
+			log.Println(l.name, "backfill", failed, "logs failed, sent to dead letter")
+		}
+	}
+
+	names := make([]string, len(tokens))
+	for i, t := range tokens {
+		names[i] = t.Hex()
+	}
+
+	return repo.MarkDebtTokensSynced(l.chainID, names)
+}
@@ -0,0 +1,576 @@
+package listener
+
+import (
+	"bytes"
+	"context"
+	"contract-listener/internal/chain"
+	"contract-listener/internal/checkpoint"
+	"contract-listener/internal/metrics"
+	"contract-listener/internal/model"
+	"contract-listener/internal/repo"
+	"contract-listener/internal/service"
+	"contract-listener/internal/worker"
+	"contract-listener/pkg/config"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+var (
+	// 运行中发现重组，检查点已回退，需要从分叉点重放
+	ErrReorg = errors.New("reorg detected, checkpoint rewound")
+
+	// 截止时间内队列没处理完，检查点只推进到已处理部分
+	ErrDrainTimeout = errors.New("drain worker queue timeout")
+
+	// 发现了新的监听地址（新池子的 DebtToken），需要补扫后重新订阅
+	ErrAddrsChanged = errors.New("watched addresses changed")
+
+	// calldata 索引没配合约部署区块，不启动
+	ErrNoStartBlock = errors.New("calldata indexing requires start_block (the contract deployment block)")
+
+	// 已处理的日志所在区块在派发之后被重组掉了
+	errForkedLogs = errors.New("processed logs are no longer canonical")
+)
+
+// 出错后多久重启
+const restartDelay = 10 * time.Second
+
+// 各合约共用的运行参数
+type Options struct {
+	WorkerNum       int
+	WorkerQueue     int
+	ShutdownTimeout time.Duration
+	Scan            chain.ScanOptions
+	DeadLetter      DeadLetterOptions
+	Stream          StreamOptions
+}
+
+// 一条链上一个合约的监听：独立的检查点、确认缓冲、worker 池
+type Listener struct {
+	name          string   // 链名/合约名，用于日志
+	labels        []string // 指标标签：chain_id, 合约名
+	chainID       uint64
+	client        *ethclient.Client
+	fallback      *ethclient.Client
+	addrs         []common.Address
+	contract      string      // 检查点、死信的合约键：合约地址，自动发现的用合约名
+	discover      *discoverer // 自动发现地址，为空表示固定地址
+	startBlock    uint64
+	confirmations uint64
+	registry      *service.Registry
+	opts          Options
+}
+
+// 加载合约 ABI 并构建事件注册表
+// headers 为同一条链上共用的区块头缓存，事件处理和补扫预取都走它
+func New(chainID uint64, client, fallback *ethclient.Client, headers *chain.HeaderCache, c config.Chain, ct config.Contract, opts Options) (*Listener, error) {
+
+	parsedABI, err := loadABI(ct.ABI)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.Scan.Headers = headers
+
+	l := &Listener{
+		name:          c.Name + "/" + ct.Name,
+		labels:        []string{strconv.FormatUint(chainID, 10), ct.Name},
+		chainID:       chainID,
+		client:        client,
+		fallback:      fallback,
+		startBlock:    ct.StartBlock,
+		confirmations: *c.Confirmations,
+		registry:      service.NewRegistry(parsedABI, chainID, ct.Name, headers),
+		opts:          opts,
+	}
+
+	if ct.Discover == "" {
+		l.addrs = []common.Address{common.HexToAddress(ct.Address)}
+		l.contract = ct.Address
+		return l, nil
+	}
+
+	// 地址从同一条链上的另一个合约发现
+	for _, src := range c.Contracts {
+		if src.Name != ct.Discover {
+			continue
+		}
+
+		srcABI, err := loadABI(src.ABI)
+		if err != nil {
+			return nil, err
+		}
+
+		l.contract = strings.ToLower(ct.Name)
+		l.discover = &discoverer{pool: common.HexToAddress(src.Address), abi: srcABI}
+		return l, nil
+	}
+
+	return nil, fmt.Errorf("%s: discover contract %s not configured on chain %s", ct.Name, ct.Discover, c.Name)
+}
+
+func loadABI(path string) (abi.ABI, error) {
+
+	abiBytes, err := os.ReadFile(path)
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("read abi %s failed: %w", path, err)
+	}
+
+	parsedABI, err := abi.JSON(bytes.NewReader(abiBytes))
+	if err != nil {
+		return abi.ABI{}, fmt.Errorf("parse abi %s failed: %w", path, err)
+	}
+
+	return parsedABI, nil
+}
+
+func (l *Listener) Name() string {
+	return l.name
+}
+
+// 补扫 + 实时订阅，直到 ctx 取消或出错
+// 停机顺序：停止生产 -> 排空队列 -> 保存检查点
+func (l *Listener) Run(ctx context.Context) error {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// ============================
+	// 1. 读取检查点（持久化，重启后从这里继续）
+	// ============================
+	// 自动发现地址的合约先同步地址列表
+	var unsynced []common.Address
+	if l.discover != nil {
+		var err error
+		if unsynced, err = l.syncTokens(ctx); err != nil {
+			return fmt.Errorf("discover addresses failed: %w", err)
+		}
+	}
+
+	// 上次检查点所在区块被重组掉的话，先回退到分叉点（worker 还没启动，可以直接撤销）
+	if fork, found, err := l.detectReorg(ctx); err != nil {
+		return fmt.Errorf("check reorg failed: %w", err)
+	} else if found {
+		if err := l.rewind(fork); err != nil {
+			return fmt.Errorf("rewind to fork point failed: %w", err)
+		}
+	}
+
+	from := l.startBlock
+	last, ok, err := repo.GetLastBlock(l.chainID, l.contract)
+	if err != nil {
+		return fmt.Errorf("load checkpoint failed: %w", err)
+	}
+	if ok && last+1 > from {
+		from = last + 1
+	}
+
+	var tracker *checkpoint.Tracker
+	if from > 0 {
+		tracker = checkpoint.NewTracker(from - 1)
+	} else {
+		tracker = checkpoint.NewTracker(0)
+	}
+
+	// 把已处理完成的最高区块（连同区块哈希）落库
+	// 保存前核对处理过的日志所在区块还在主链上：派发之后才发生的重组，保存时取到的已经是新链的哈希，重启后发现不了
+	flush := func(ctx context.Context) error {
+		return tracker.Flush(func(b uint64, seen model.BlockRef) error {
+			h, err := l.client.HeaderByNumber(ctx, new(big.Int).SetUint64(b))
+			if err != nil {
+				return err
+			}
+			if seen.Hash != "" {
+				ok := seen.Hash == h.Hash().Hex()
+				if seen.Number != b {
+					if ok, err = chain.IsCanonical(ctx, l.client, seen); err != nil {
+						return err
+					}
+				}
+				if !ok {
+					return fmt.Errorf("%w: block %d", errForkedLogs, seen.Number)
+				}
+			}
+			return repo.SaveLastBlock(l.chainID, l.contract, b, h.Hash().Hex())
+		})
+	}
+
+	// 新地址先补扫到检查点，之后和其他地址一起继续
+	if err := l.backfillTokens(ctx, unsynced, from); err != nil {
+		return fmt.Errorf("backfill new addresses failed: %w", err)
+	}
+
+	// 发现重组后不能再保存内存里的进度，否则会把回退的检查点又推回去
+	var reorged atomic.Bool
+	var reorgFork atomic.Uint64
+
+	// 运行中发现新地址：停下来补扫后重新订阅
+	var addrsChanged atomic.Bool
+
+	// 定时检查重组并保存检查点
+	var bg sync.WaitGroup
+	bg.Add(1)
+	go func() {
+		defer bg.Done()
+
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			fork, found, err := l.detectReorg(ctx)
+			if err != nil {
+				log.Println(l.name, "check reorg failed:", err)
+				continue
+			}
+			if found {
+				// 内存里的进度已经不可信，停下来；队列里还有分叉链上的日志，等排空后再回退
+				reorgFork.Store(fork)
+				reorged.Store(true)
+				cancel()
+				return
+			}
+
+			err = flush(ctx)
+			if errors.Is(err, errForkedLogs) {
+				// 上次保存的检查点核对过哈希，从那里重放
+				log.Println(l.name, err)
+				reorgFork.Store(tracker.Saved())
+				reorged.Store(true)
+				cancel()
+				return
+			}
+			if err != nil {
+				log.Println(l.name, "save checkpoint failed:", err)
+			}
+		}
+	}()
+
+	if l.discover != nil {
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+
+			ticker := time.NewTicker(discoverInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+
+				changed, err := l.tokensChanged(ctx)
+				if err != nil {
+					log.Println(l.name, "discover addresses failed:", err)
+					continue
+				}
+				if changed {
+					addrsChanged.Store(true)
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+	// ============================
+	// 2. 日志 channel（必须大buffer）
+	// ============================
+	logs := make(chan types.Log, 10000)
+
+	// ============================
+	// 3. 启动 worker池
+	// ============================
+	// 按 Registry.Key 分区：同一用户（PledgePool 为同一合约）的事件严格有序
+	pool := worker.Start(l.opts.WorkerNum, l.opts.WorkerQueue, logs, l.registry.Key, func(v types.Log) {
+
+		// 按 topic 解码并分发，失败的进死信队列
+		if err := l.registry.Handle(v); err != nil {
+			log.Println(l.name, err)
+
+			// 死信也写不进去时不推进检查点，重启后从这里重放
+			if err := l.deadLetter(v, err); err != nil {
+				log.Println(l.name, "save dead letter failed, checkpoint held at", v.BlockNumber, err)
+				return
+			}
+		}
+
+		// 处理完成后推进检查点
+		tracker.Done(v.BlockNumber)
+	})
+
+	// 到期死信按退避重试
+	bg.Add(1)
+	go func() {
+		defer bg.Done()
+		l.retryDeadLetters(ctx)
+	}()
+
+	// 已保存检查点的事件投递到 Redis Stream（可选）
+	bg.Add(1)
+	go func(addrs []common.Address) {
+		defer bg.Done()
+		l.publishStream(ctx, addrs)
+	}(append([]common.Address(nil), l.addrs...))
+
+	// 定时更新进度、积压指标，并输出各分区排队情况
+	go func() {
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+
+		for i := 1; ; i++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			l.observe(ctx, tracker, pool, logs)
+
+			if i%6 == 0 {
+				log.Println(l.name, "worker queue depths", pool.Depths(), "logs channel", len(logs))
+			}
+		}
+	}()
+
+	var runErr error
+
+	// 还没有可监听的地址（没有池子），等发现新地址
+	if len(l.addrs) == 0 {
+		log.Println(l.name, "no address to watch, waiting for discovery")
+	}
+
+	// ============================
+	// 4. 历史补扫（生产必须）
+	// ============================
+	latest, err := l.client.BlockNumber(ctx)
+	if err != nil && ctx.Err() == nil {
+		runErr = fmt.Errorf("get latest block failed: %w", err)
+		cancel()
+	}
+
+	// 只补扫已确认的区块，未确认部分交给订阅的确认缓冲
+	safe := uint64(0)
+	if latest > l.confirmations {
+		safe = latest - l.confirmations
+	}
+
+	if ctx.Err() == nil && len(l.addrs) > 0 && from <= safe {
+
+		log.Println(l.name, "scan history", from, "->", safe)
+
+		err = chain.ScanHistory(ctx, l.client, l.addrs, from, safe, logs, tracker, l.opts.Scan)
+		if err != nil && ctx.Err() == nil {
+			runErr = fmt.Errorf("scan history failed: %w", err)
+			cancel()
+		}
+
+		from = safe + 1
+	}
+
+	// ============================
+	// 5. 实时订阅（生产必须）
+	// ============================
+	var producers sync.WaitGroup
+	if ctx.Err() == nil && len(l.addrs) > 0 {
+		producers.Add(1)
+		go func() {
+			defer producers.Done()
+			chain.SubscribeLoop(ctx, l.client, l.fallback, l.addrs, from, l.confirmations, logs, tracker, func() {
+				metrics.Reconnects.WithLabelValues(l.labels...).Inc()
+			})
+		}()
+
+		log.Println(l.name, "listener started...")
+	}
+
+	// ============================
+	// 6. 停机：停止生产 -> 排空队列 -> 保存检查点
+	// ============================
+	<-ctx.Done()
+
+	// 订阅和补扫都已停止写入后才能关闭 logs
+	producers.Wait()
+	bg.Wait()
+	close(logs)
+
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), l.opts.ShutdownTimeout)
+	defer cancelDrain()
+
+	drainErr := pool.Wait(drainCtx)
+	if drainErr != nil {
+		log.Println(l.name, "drain worker queue timeout, left", pool.Depths())
+		if runErr == nil {
+			runErr = ErrDrainTimeout
+		}
+	}
+
+	// 队列排空后再撤销分叉点之后的数据，排空期间写入的分叉链事件也一并撤销
+	// 排空超时说明还有 worker 在写，这时不撤销，检查点仍在分叉链上，下次 Run 启动前会重新检测并回退
+	if reorged.Load() {
+		if drainErr != nil {
+			return ErrDrainTimeout
+		}
+		if err := l.rewind(reorgFork.Load()); err != nil {
+			return fmt.Errorf("rewind to fork point failed: %w", err)
+		}
+		return ErrReorg
+	}
+
+	// 超时的话只保存已处理完的部分，剩下的重启后重放（事件表幂等）
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFlush()
+
+	if err := flush(flushCtx); errors.Is(err, errForkedLogs) {
+		log.Println(l.name, err)
+		if drainErr != nil {
+			return ErrDrainTimeout
+		}
+		if err := l.rewind(tracker.Saved()); err != nil {
+			return fmt.Errorf("rewind to fork point failed: %w", err)
+		}
+		return ErrReorg
+	} else if err != nil {
+		return fmt.Errorf("save checkpoint failed: %w", err)
+	}
+
+	log.Println(l.name, "checkpoint saved at", tracker.Committed())
+
+	if addrsChanged.Load() && runErr == nil {
+		return ErrAddrsChanged
+	}
+
+	return runErr
+}
+
+// 更新最新区块、已处理区块、落后区块数和积压指标
+func (l *Listener) observe(ctx context.Context, tracker *checkpoint.Tracker, pool *worker.Pool, logs chan types.Log) {
+
+	processed := tracker.Committed()
+	metrics.ProcessedBlock.WithLabelValues(l.labels...).Set(float64(processed))
+
+	queued := 0
+	for _, d := range pool.Depths() {
+		queued += d
+	}
+	metrics.WorkerQueue.WithLabelValues(l.labels...).Set(float64(queued))
+	metrics.LogsChannel.WithLabelValues(l.labels...).Set(float64(len(logs)))
+
+	head, err := l.client.BlockNumber(ctx)
+	if err != nil {
+		return
+	}
+	metrics.HeadBlock.WithLabelValues(l.labels...).Set(float64(head))
+
+	lag := uint64(0)
+	if head > processed {
+		lag = head - processed
+	}
+	metrics.Lag.WithLabelValues(l.labels...).Set(float64(lag))
+}
+
+// 节点是否可用（就绪检查用）
+func (l *Listener) Ping(ctx context.Context) error {
+	_, err := l.client.BlockNumber(ctx)
+	return err
+}
+
+// 检查最近一次检查点是否还在主链上，不在则返回分叉点
+func (l *Listener) detectReorg(ctx context.Context) (uint64, bool, error) {
+
+	refs, err := repo.GetBlockHashes(l.chainID, l.contract)
+	if err != nil || len(refs) == 0 {
+		return 0, false, err
+	}
+
+	ok, err := chain.IsCanonical(ctx, l.client, refs[0])
+	if err != nil || ok {
+		return 0, false, err
+	}
+
+	fork, found, err := chain.FindForkPoint(ctx, l.client, refs[1:])
+	if err != nil {
+		return 0, false, err
+	}
+	if !found {
+		return 0, false, fmt.Errorf("reorg deeper than saved history, checkpoint %d", refs[0].Number)
+	}
+
+	log.Println(l.name, "reorg detected at", refs[0].Number, "fork point", fork)
+
+	return fork, true, nil
+}
+
+// 撤销分叉点之后已入库的事件和派生数据并回退检查点，重放时会重新写入
+// 必须在 worker 停止后调用，否则还在队列里的分叉链日志会在撤销之后写入
+func (l *Listener) rewind(fork uint64) error {
+
+	log.Println(l.name, "rewind checkpoint to", fork)
+
+	for _, a := range l.addrs {
+		if err := repo.MarkEventsRemovedAfter(l.chainID, a.Hex(), fork); err != nil {
+			return err
+		}
+		if err := repo.SaveWebhookRetractsAfter(l.chainID, a.Hex(), fork); err != nil {
+			return err
+		}
+	}
+	if err := l.registry.Rewind(fork, l.addrs); err != nil {
+		return err
+	}
+
+	return repo.RewindLastBlock(l.chainID, l.contract, fork)
+}
+
+// 出错或发现重组后重新 Run，单个合约出问题不影响同进程的其他合约
+// ctx 取消后返回最后一次 Run 的结果
+func (l *Listener) Serve(ctx context.Context) error {
+
+	for {
+
+		err := l.Run(ctx)
+		if ctx.Err() != nil {
+			if errors.Is(err, ErrReorg) || errors.Is(err, ErrAddrsChanged) {
+				return nil
+			}
+			return err
+		}
+
+		if errors.Is(err, ErrReorg) {
+			log.Println(l.name, "reorg detected, replay from fork point")
+			continue
+		}
+		if errors.Is(err, ErrAddrsChanged) {
+			log.Println(l.name, "watched addresses changed, restart")
+			continue
+		}
+
+		log.Println(l.name, "stopped:", err, "restart in", restartDelay)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(restartDelay):
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package listener
+
+import (
+	"context"
+	"contract-listener/internal/chain"
+	"contract-listener/internal/checkpoint"
+	"contract-listener/internal/service"
+	"contract-listener/internal/worker"
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// 重放参数
+type ReplayOptions struct {
+	From   uint64
+	To     uint64
+	Event  string // 为空表示全部事件
+	DryRun bool   // 只解码打印，不入库不处理
+}
+
+// 把 [From, To] 的日志重新走一遍解码和处理流程
+// 不订阅、不读写检查点，只处理已确认的区块；处理函数不管是否重复都会调用
+func (l *Listener) Replay(ctx context.Context, o ReplayOptions) error {
+
+	if o.From > o.To {
+		return fmt.Errorf("from %d is after to %d", o.From, o.To)
+	}
+
+	latest, err := l.client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("get latest block failed: %w", err)
+	}
+	if latest < l.confirmations || o.To > latest-l.confirmations {
+		return fmt.Errorf("to %d is not confirmed yet, latest %d, confirmations %d", o.To, latest, l.confirmations)
+	}
+
+	scan := l.opts.Scan
+	if o.Event != "" {
+		topic, ok := l.registry.Topic(o.Event)
+		if !ok {
+			return fmt.Errorf("event %s not in %s abi", o.Event, l.name)
+		}
+		scan.Topics = [][]common.Hash{{topic}}
+	}
+
+	if o.DryRun {
+		l.registry.SetMode(service.ModeDryRun)
+	} else {
+		l.registry.SetMode(service.ModeReplay)
+	}
+
+	// 自动发现的代币按链上当前的池子列表重放
+	if l.discover != nil {
+		tokens, err := l.discover.tokens(ctx, l.client, l.chainID)
+		if err != nil {
+			return err
+		}
+		l.addrs = l.addrs[:0]
+		for _, t := range tokens {
+			l.addrs = append(l.addrs, common.HexToAddress(t.Token))
+		}
+	}
+
+	log.Println(l.name, "replay", o.From, "->", o.To, "event", o.Event, "dry-run", o.DryRun)
+
+	failed, err := l.scanRange(ctx, l.addrs, o.From, o.To, scan, o.DryRun)
+	if err != nil {
+		return err
+	}
+
+	log.Println(l.name, "replay done", o.From, "->", o.To)
+
+	if failed > 0 {
+		return fmt.Errorf("%d logs failed", failed)
+	}
+
+	return nil
+}
+
+// 用临时的检查点和 worker 池把 addrs 在 [from, to] 的日志处理一遍，不读写检查点
+// 失败的日志进死信队列（dryRun 除外），返回失败条数
+func (l *Listener) scanRange(ctx context.Context, addrs []common.Address, from, to uint64, scan chain.ScanOptions, dryRun bool) (int64, error) {
+
+	// 不指定地址的 FilterLogs 会拉全链日志
+	if len(addrs) == 0 {
+		return 0, nil
+	}
+
+	// 只用于补扫接口，不落库
+	var tracker *checkpoint.Tracker
+	if from > 0 {
+		tracker = checkpoint.NewTracker(from - 1)
+	} else {
+		tracker = checkpoint.NewTracker(0)
+	}
+
+	logs := make(chan types.Log, 10000)
+
+	var failed atomic.Int64
+	pool := worker.Start(l.opts.WorkerNum, l.opts.WorkerQueue, logs, l.registry.Key, func(v types.Log) {
+
+		defer tracker.Done(v.BlockNumber)
+
+		if err := l.registry.Handle(v); err != nil {
+			log.Println(l.name, err)
+			failed.Add(1)
+
+			if !dryRun {
+				if err := l.deadLetter(v, err); err != nil {
+					log.Println(l.name, "save dead letter failed:", err)
+				}
+			}
+		}
+	})
+
+	scanErr := chain.ScanHistory(ctx, l.client, addrs, from, to, logs, tracker, scan)
+
+	// 已派发的日志处理完再退出；中断时最多等 ShutdownTimeout
+	close(logs)
+
+	drainCtx := context.Background()
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		drainCtx, cancel = context.WithTimeout(drainCtx, l.opts.ShutdownTimeout)
+		defer cancel()
+	}
+
+	if err := pool.Wait(drainCtx); err != nil {
+		return failed.Load(), ErrDrainTimeout
+	}
+
+	if scanErr != nil {
+		return failed.Load(), fmt.Errorf("scan stopped after block %d: %w", tracker.Committed(), scanErr)
+	}
+
+	return failed.Load(), nil
+}
@@ -0,0 +1,107 @@
+package listener
+
+import (
+	"context"
+	"contract-listener/internal/notify"
+	"contract-listener/internal/repo"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 每次从数据库读多少条事件追加到 Stream
+const streamBatch = 500
+
+// Redis Stream 参数，Prefix 为空不启用
+type StreamOptions struct {
+	Prefix   string
+	MaxLen   int64 // 每个 Stream 近似保留的条数
+	Interval time.Duration
+}
+
+// 每个合约地址一个 Stream：<prefix>:<chain_id>:<address>
+func (o StreamOptions) key(chainID uint64, addr common.Address) string {
+	return fmt.Sprintf("%s:%d:%s", o.Prefix, chainID, strings.ToLower(addr.Hex()))
+}
+
+// 把已落库且检查点已保存的事件追加到 Redis Stream，投递状态按事件记录在 contract_event.streamed_at：
+// 先追加、再标记，崩溃后没标记的重新投递，至少一次
+// 死信重试后才入库的旧事件、重组回退后重新写入的事件都会补投；投递过又被撤销的事件追加一条撤回
+func (l *Listener) publishStream(ctx context.Context, addrs []common.Address) {
+
+	o := l.opts.Stream
+	if o.Prefix == "" || !notify.StreamEnabled() {
+		return
+	}
+
+	ticker := time.NewTicker(o.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		committed, ok, err := repo.GetLastBlock(l.chainID, l.contract)
+		if err != nil {
+			log.Println(l.name, "stream: load checkpoint failed:", err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+
+		for _, addr := range addrs {
+			if err := l.appendStream(ctx, addr, committed); err != nil {
+				log.Println(l.name, "stream", addr.Hex(), "failed:", err)
+			}
+		}
+	}
+}
+
+// 追加 addr 还没投递的事件（到 committed 区块为止）和需要撤回的事件
+func (l *Listener) appendStream(ctx context.Context, addr common.Address, committed uint64) error {
+
+	key := l.opts.Stream.key(l.chainID, addr)
+
+	for ctx.Err() == nil {
+
+		events, err := repo.GetUnstreamedEvents(l.chainID, addr.Hex(), committed, streamBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			err := notify.AppendStream(key, l.opts.Stream.MaxLen, notify.StreamEvent{
+				ChainID:   strconv.FormatUint(e.ChainID, 10),
+				Contract:  addr.Hex(),
+				Event:     e.Name,
+				Block:     e.Block,
+				BlockHash: e.BlockHash,
+				BlockTime: e.BlockTime.Unix(),
+				TxHash:    e.TxHash,
+				LogIndex:  e.LogIndex,
+				Removed:   e.Removed,
+				Args:      e.Args,
+			})
+			if err != nil {
+				return err
+			}
+			if err := repo.MarkEventStreamed(e); err != nil {
+				return err
+			}
+		}
+
+		if len(events) < streamBatch {
+			return nil
+		}
+	}
+
+	return ctx.Err()
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 标签：chain_id + 配置里的合约名
+var (
+	HeadBlock = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "listener_head_block",
+		Help: "Latest block number seen on the chain.",
+	}, []string{"chain_id", "contract"})
+
+	ProcessedBlock = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "listener_processed_block",
+		Help: "Highest block whose logs are all processed.",
+	}, []string{"chain_id", "contract"})
+
+	Lag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "listener_lag_blocks",
+		Help: "Head block minus processed block.",
+	}, []string{"chain_id", "contract"})
+
+	EventsDecoded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "listener_events_decoded_total",
+		Help: "Events decoded and handled, by event name.",
+	}, []string{"chain_id", "contract", "event"})
+
+	DecodeFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "listener_decode_failures_total",
+		Help: "Logs that failed to decode, by event name.",
+	}, []string{"chain_id", "contract", "event"})
+
+	DeadLetters = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "listener_dead_letters_total",
+		Help: "Logs written to the dead-letter queue, by event name.",
+	}, []string{"chain_id", "contract", "event"})
+
+	Reconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "listener_reconnects_total",
+		Help: "Live subscription drops followed by a reconnect.",
+	}, []string{"chain_id", "contract"})
+
+	LogsChannel = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "listener_logs_channel",
+		Help: "Logs waiting in the dispatch channel.",
+	}, []string{"chain_id", "contract"})
+
+	WorkerQueue = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "listener_worker_queue",
+		Help: "Logs waiting in the worker partitions.",
+	}, []string{"chain_id", "contract"})
+
+	// 区块头缓存按链共用，只有 chain_id 标签
+	HeaderCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "listener_header_cache_total",
+		Help: "Block header lookups, by result (hit / miss).",
+	}, []string{"chain_id", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		HeadBlock,
+		ProcessedBlock,
+		Lag,
+		EventsDecoded,
+		DecodeFailures,
+		DeadLetters,
+		Reconnects,
+		LogsChannel,
+		WorkerQueue,
+		HeaderCache,
+	)
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 死信状态
+const (
+	DeadLetterPending = "pending" // 等待自动重试
+	DeadLetterFailed  = "failed"  // 重试次数用完，需要人工 requeue
+	DeadLetterDone    = "done"    // 重试成功
+)
+
+// 解码或处理失败的日志
+// 唯一键 (ChainID, TxHash, LogIndex)
+type DeadLetter struct {
+	ID          int64
+	ChainID     uint64
+	Contract    string
+	TxHash      string
+	LogIndex    uint
+	Block       uint64
+	Event       string          // 事件名，未知 topic 为空
+	Log         json.RawMessage // 原始日志（types.Log 的 JSON）
+	Error       string
+	Attempts    int
+	Status      string
+	NextRetryAt time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
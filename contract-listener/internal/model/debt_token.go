@@ -0,0 +1,32 @@
+package model
+
+import "math/big"
+
+// DebtToken 方向
+const (
+	DebtSideSP = "sp" // 出借人凭证
+	DebtSideJP = "jp" // 借款人凭证
+)
+
+// 从 PledgePool 池子信息发现的 DebtToken
+// 唯一键 (ChainID, Token)；同一代币被多个池子使用时归到 pid 最小的池子
+type DebtToken struct {
+	ChainID uint64
+	Token   string
+	Pool    string // PledgePool 合约地址
+	PoolID  uint64 // 合约里的 pid
+	Side    string
+}
+
+// DebtToken 的一次 Transfer（铸造 from 为零地址，销毁 to 为零地址）
+// 唯一键 (ChainID, TxHash, LogIndex)
+type DebtTransfer struct {
+	ChainID  uint64
+	Token    string
+	From     string
+	To       string
+	Amount   *big.Int
+	TxHash   string
+	LogIndex uint
+	Block    uint64
+}
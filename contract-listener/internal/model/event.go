@@ -1,8 +1,27 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // 数据库存储的事件结构
+// 唯一键 (ChainID, TxHash, LogIndex)
 type Event struct {
-	TxHash   string
-	Block    uint64
-	LogIndex uint
+	ChainID   uint64
+	TxHash    string
+	Block     uint64
+	BlockHash string
+	BlockTime time.Time
+	LogIndex  uint
+	Contract  string
+	Name      string
+	Args      json.RawMessage // 解码后的参数，key 为 ABI 参数名，大整数用十进制字符串
+	Removed   bool
+}
+
+// 检查点区块及其哈希，用于发现重组
+type BlockRef struct {
+	Number uint64
+	Hash   string
 }
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// 协议参数变更（管理事件 + setPause 交易）
+// 唯一键 (ChainID, TxHash, LogIndex, Param)；calldata 来源的 LogIndex 为交易在区块内的序号
+type GovernanceChange struct {
+	ChainID   uint64
+	Contract  string
+	Param     string // lend_fee / borrow_fee / swap_router / fee_address / min_amount / paused / owner
+	Old       string
+	New       string
+	TxHash    string
+	LogIndex  uint
+	Block     uint64
+	BlockTime time.Time
+	Sender    string // 交易发起人
+	Critical  bool   // 需要告警
+}
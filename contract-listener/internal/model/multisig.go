@@ -0,0 +1,37 @@
+package model
+
+// 多签申请状态
+const (
+	MultisigPending  = "pending"  // 有效签名数未达到阈值
+	MultisigApproved = "approved" // 有效签名数达到阈值，业务合约的 validCall 可以通过
+	MultisigRevoked  = "revoked"  // 签过名但已全部撤回
+)
+
+// multiSignature 合约的一次申请（createApplication）
+// 唯一键 (ChainID, TxHash, LogIndex)；同一 MsgHash 可以多次申请，Index 为在 signatureMap 里的下标
+type MultisigApplication struct {
+	ChainID   uint64
+	Contract  string
+	MsgHash   string
+	Index     uint64
+	Applicant string
+	Target    string // 业务合约地址，msgHash = keccak256(applicant, target)
+	Threshold uint64
+	TxHash    string
+	LogIndex  uint
+	Block     uint64
+}
+
+// 管理员对申请的签名 / 撤回，每个签名人只保留最新一次操作
+// 唯一键 (ChainID, Contract, MsgHash, Index, Signer)
+type MultisigSignature struct {
+	ChainID  uint64
+	Contract string
+	MsgHash  string
+	Index    uint64
+	Signer   string
+	Signed   bool
+	TxHash   string
+	LogIndex uint
+	Block    uint64
+}
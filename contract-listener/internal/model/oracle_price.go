@@ -0,0 +1,21 @@
+package model
+
+import (
+	"math/big"
+	"time"
+)
+
+// BscPledgeOracle 的一次喂价（合约不发事件，从成功交易的 calldata 解析）
+// 唯一键 (ChainID, TxHash, Seq)，Seq 为 setPrices 批量里的下标
+type OraclePrice struct {
+	ChainID    uint64
+	Oracle     string
+	TxHash     string
+	Seq        int
+	Method     string
+	Underlying *big.Int // priceMap 实际写入的 key
+	Requested  *big.Int // 调用参数里的资产（setPrices 与 Underlying 不同，见 service.OraclePrices）
+	Price      *big.Int
+	Block      uint64
+	BlockTime  time.Time
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 投递状态
+const (
+	WebhookPending   = "pending"   // 等待投递或重试
+	WebhookDelivered = "delivered" // 对方返回 2xx
+	WebhookFailed    = "failed"    // 重试次数用完
+)
+
+// 后台配置的 webhook，过滤条件为空表示不限
+// 只推送创建之后出块的事件，重放历史区块不会补推
+type Webhook struct {
+	ID        int64
+	URL       string
+	Secret    string   // HMAC-SHA256 签名密钥
+	ChainID   uint64   // 0 表示所有链
+	Events    []string // 事件名
+	PoolIDs   []int64  // PledgePool 的 pid（从 0 开始）
+	Addresses []string // 合约地址或事件参数里的任意地址，小写
+	CreatedAt time.Time
+}
+
+// 一个事件对一个 webhook 的投递，Removed 为重组后的撤回
+// 唯一键 (WebhookID, ChainID, TxHash, LogIndex, Removed)
+type WebhookDelivery struct {
+	ID          int64
+	WebhookID   int64
+	ChainID     uint64
+	TxHash      string
+	LogIndex    uint
+	Event       string
+	Payload     json.RawMessage // 投递的请求体
+	Removed     bool
+	Attempts    int
+	Status      string
+	NextRetryAt time.Time
+
+	// 投递时从 webhook 读取
+	URL    string
+	Secret string
+}
@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gomodule/redigo/redis"
+)
+
+// 发布到 pledge-backend 的 Redis 频道，未配置 Redis 时不发布
+var (
+	pool     *redis.Pool
+	channels Channels
+)
+
+// 频道名和缓存 key 前缀，和 pledge-backend 配置里的 [listener] 一致
+type Channels struct {
+	PoolRefresh string
+	Governance  string
+	Positions   string // 用户持仓缓存 key 前缀
+}
+
+// 池子需要刷新：pledge-backend 收到后只刷新这一个池子的 poolbases / pooldata
+type PoolRefresh struct {
+	ChainID   string `json:"chain_id"`
+	PoolID    int64  `json:"pool_id"` // 合约里的 pid，从 0 开始
+	Event     string `json:"event"`
+	Block     uint64 `json:"block"`
+	BlockHash string `json:"block_hash"`
+	BlockTime int64  `json:"block_time"` // 区块时间，unix 秒
+	TxHash    string `json:"tx_hash"`
+}
+
+// 关键协议参数变更：pledge-backend 收到后发告警邮件
+type Governance struct {
+	ChainID   string `json:"chain_id"`
+	Contract  string `json:"contract"`
+	Param     string `json:"param"`
+	Old       string `json:"old"`
+	New       string `json:"new"`
+	Sender    string `json:"sender"`
+	Block     uint64 `json:"block"`
+	BlockTime int64  `json:"block_time"`
+	TxHash    string `json:"tx_hash"`
+}
+
+// 初始化 Redis 连接池，addr 为空时不启用
+func InitRedis(addr, password string, db int, c Channels) error {
+
+	if addr == "" {
+		return nil
+	}
+
+	p, err := newPool(addr, password, db)
+	if err != nil {
+		return err
+	}
+
+	pool = p
+	channels = c
+
+	return nil
+}
+
+func newPool(addr, password string, db int) (*redis.Pool, error) {
+
+	p := &redis.Pool{
+		MaxIdle:     5,
+		IdleTimeout: 180 * time.Second,
+		Wait:        true,
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr,
+				redis.DialPassword(password),
+				redis.DialDatabase(db),
+				redis.DialConnectTimeout(5*time.Second),
+			)
+		},
+	}
+
+	conn := p.Get()
+	defer conn.Close()
+
+	if _, err := conn.Do("PING"); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// 是否启用
+func Enabled() bool {
+	return pool != nil
+}
+
+// 通知刷新池子
+func PublishPoolRefresh(m PoolRefresh) error {
+	return publish(channels.PoolRefresh, m)
+}
+
+// 关键参数变更告警
+func PublishGovernance(m Governance) error {
+	return publish(channels.Governance, m)
+}
+
+// 删除这些用户的持仓缓存，pledge-backend 下次查询时重新读链
+func InvalidatePositions(chainID uint64, users ...common.Address) error {
+
+	if pool == nil || channels.Positions == "" {
+		return nil
+	}
+
+	args := redis.Args{}
+	for _, u := range users {
+		// 零地址是铸造 / 销毁的对手方
+		if u == (common.Address{}) {
+			continue
+		}
+		args = args.Add(fmt.Sprintf("%s:%d:%s", channels.Positions, chainID, strings.ToLower(u.Hex())))
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("DEL", args...)
+
+	return err
+}
+
+func publish(channel string, msg any) error {
+
+	if pool == nil || channel == "" {
+		return nil
+	}
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	conn := pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("PUBLISH", channel, b)
+
+	return err
+}
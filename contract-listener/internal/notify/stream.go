@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"encoding/json"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// Stream 单独一个库：pledge-backend 的 schedule 启动时会 FLUSHDB 通知用的库，没消费的条目不能放在那里
+var streamPool *redis.Pool
+
+// 初始化 Stream 用的连接池，addr 为空时不启用
+func InitStream(addr, password string, db int) error {
+
+	if addr == "" {
+		return nil
+	}
+
+	p, err := newPool(addr, password, db)
+	if err != nil {
+		return err
+	}
+
+	streamPool = p
+
+	return nil
+}
+
+// 是否启用 Stream
+func StreamEnabled() bool {
+	return streamPool != nil
+}
+
+// 追加到 Redis Stream 的事件，条目 ID 由 Redis 生成，下游按 (chain_id, tx_hash, log_index) 去重
+// 至少一次：同一事件可能重复出现；removed=true 表示撤回之前投递过的同一事件（所在区块被重组掉）
+type StreamEvent struct {
+	ChainID   string          `json:"chain_id"`
+	Contract  string          `json:"contract"`
+	Event     string          `json:"event"`
+	Block     uint64          `json:"block"`
+	BlockHash string          `json:"block_hash"`
+	BlockTime int64           `json:"block_time"` // 区块时间，unix 秒
+	TxHash    string          `json:"tx_hash"`
+	LogIndex  uint            `json:"log_index"`
+	Removed   bool            `json:"removed"`
+	Args      json.RawMessage `json:"args"` // key 为 ABI 参数名，大整数用十进制字符串
+}
+
+// 追加一条事件，按 maxLen 近似裁剪
+func AppendStream(key string, maxLen int64, e StreamEvent) error {
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	conn := streamPool.Get()
+	defer conn.Close()
+
+	args := redis.Args{key}
+	if maxLen > 0 {
+		args = args.Add("MAXLEN", "~", maxLen)
+	}
+	args = args.Add("*", "event", e.Event, "data", b)
+
+	_, err = conn.Do("XADD", args...)
+
+	return err
+}
@@ -0,0 +1,131 @@
+package ops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 就绪检查项超时
+const checkTimeout = 3 * time.Second
+
+// 就绪检查：返回 nil 表示可用
+type Check func(ctx context.Context) error
+
+// 运维 HTTP 服务：/healthz 存活，/readyz 就绪（节点、数据库），/metrics Prometheus 指标
+type Server struct {
+	srv    *http.Server
+	mu     sync.Mutex
+	checks map[string]Check
+}
+
+func NewServer(addr string) *Server {
+
+	s := &Server{checks: make(map[string]Check)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", s.healthz)
+	mux.HandleFunc("/readyz", s.readyz)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	return s
+}
+
+// 注册就绪检查项，name 用于输出
+func (s *Server) AddCheck(name string, c Check) {
+	s.mu.Lock()
+	s.checks[name] = c
+	s.mu.Unlock()
+}
+
+// 阻塞运行，ctx 取消后关闭
+func (s *Server) Run(ctx context.Context) error {
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = s.srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Println("ops server listening on", s.srv.Addr)
+
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+// 进程存活即可
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// 所有检查项通过才就绪，任何一项失败返回 503
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+
+	s.mu.Lock()
+	checks := make(map[string]Check, len(s.checks))
+	names := make([]string, 0, len(s.checks))
+	for name, c := range s.checks {
+		checks[name] = c
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+
+	sort.Strings(names)
+
+	type result struct {
+		name string
+		err  error
+	}
+
+	results := make([]result, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+			defer cancel()
+
+			results[i] = result{name, checks[name](ctx)}
+		}(i, name)
+	}
+	wg.Wait()
+
+	var b strings.Builder
+	status := http.StatusOK
+
+	for _, res := range results {
+		if res.err != nil {
+			status = http.StatusServiceUnavailable
+			fmt.Fprintf(&b, "%s: %v\n", res.name, res.err)
+			continue
+		}
+		fmt.Fprintf(&b, "%s: ok\n", res.name)
+	}
+
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(b.String()))
+}
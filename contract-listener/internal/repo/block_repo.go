@@ -1,13 +1,136 @@
 package repo
 
-var last uint64 = 0
+import (
+	"contract-listener/internal/model"
+	"database/sql"
+	"errors"
+	"strings"
+)
 
-func GetLastBlock() uint64 {
-	return last
+// 保留最近多少个检查点哈希用于找分叉点
+const keepHashes = 256
+
+// 读取合约已处理完成的最高区块，ok=false 表示还没有检查点
+func GetLastBlock(chainID uint64, contract string) (uint64, bool, error) {
+
+	var b uint64
+
+	err := DB.QueryRow(
+		`SELECT last_block FROM block_checkpoint WHERE chain_id = $1 AND contract = $2`,
+		chainID, strings.ToLower(contract),
+	).Scan(&b)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return b, true, nil
 }
 
-func SaveLastBlock(b uint64) {
-	if b > last {
-		last = b
+// 保存检查点（只前进不后退）及该区块哈希
+func SaveLastBlock(chainID uint64, contract string, b uint64, hash string) error {
+
+	contract = strings.ToLower(contract)
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO block_checkpoint (chain_id, contract, last_block)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (chain_id, contract) DO UPDATE
+		SET last_block = GREATEST(block_checkpoint.last_block, EXCLUDED.last_block),
+			updated_at = now()`,
+		chainID, contract, b,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO block_hash (chain_id, contract, number, hash)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (chain_id, contract, number) DO UPDATE SET hash = EXCLUDED.hash`,
+		chainID, contract, b, hash,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM block_hash WHERE chain_id = $1 AND contract = $2 AND number < (
+			SELECT COALESCE(MIN(number), 0) FROM (
+				SELECT number FROM block_hash WHERE chain_id = $1 AND contract = $2
+				ORDER BY number DESC LIMIT $3
+			) t
+		)`,
+		chainID, contract, keepHashes,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// 最近保存的检查点哈希，区块号从高到低
+func GetBlockHashes(chainID uint64, contract string) ([]model.BlockRef, error) {
+
+	rows, err := DB.Query(
+		`SELECT number, hash FROM block_hash WHERE chain_id = $1 AND contract = $2
+		ORDER BY number DESC LIMIT $3`,
+		chainID, strings.ToLower(contract), keepHashes,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []model.BlockRef
+	for rows.Next() {
+		var r model.BlockRef
+		if err := rows.Scan(&r.Number, &r.Hash); err != nil {
+			return nil, err
+		}
+		refs = append(refs, r)
+	}
+
+	return refs, rows.Err()
+}
+
+// 发生重组时把检查点回退到分叉点
+func RewindLastBlock(chainID uint64, contract string, b uint64) error {
+
+	contract = strings.ToLower(contract)
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`UPDATE block_checkpoint SET last_block = $3, updated_at = now()
+		WHERE chain_id = $1 AND contract = $2`,
+		chainID, contract, b,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`DELETE FROM block_hash WHERE chain_id = $1 AND contract = $2 AND number > $3`,
+		chainID, contract, b,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,240 @@
+package repo
+
+import (
+	"database/sql"
+
+	_ "github.com/lib/pq"
+)
+
+var DB *sql.DB
+
+// 建表语句（启动时自动执行，已存在则跳过）
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS block_checkpoint (
+		chain_id   BIGINT      NOT NULL,
+		contract   VARCHAR(42) NOT NULL,
+		last_block BIGINT      NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (chain_id, contract)
+	)`,
+	`CREATE TABLE IF NOT EXISTS block_hash (
+		chain_id BIGINT      NOT NULL,
+		contract VARCHAR(42) NOT NULL,
+		number   BIGINT      NOT NULL,
+		hash     VARCHAR(66) NOT NULL,
+		PRIMARY KEY (chain_id, contract, number)
+	)`,
+	`CREATE TABLE IF NOT EXISTS contract_event (
+		chain_id     BIGINT      NOT NULL,
+		tx_hash      VARCHAR(66) NOT NULL,
+		log_index    INT         NOT NULL,
+		block_number BIGINT      NOT NULL,
+		block_hash   VARCHAR(66) NOT NULL,
+		block_time   TIMESTAMPTZ NOT NULL,
+		contract     VARCHAR(42) NOT NULL,
+		event_name   VARCHAR(64) NOT NULL,
+		args         JSONB       NOT NULL,
+		removed      BOOLEAN     NOT NULL DEFAULT false,
+		streamed_at  TIMESTAMPTZ,
+		created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (chain_id, tx_hash, log_index)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_contract_event_name
+		ON contract_event (chain_id, contract, event_name, block_number)`,
+	`CREATE INDEX IF NOT EXISTS idx_contract_event_block
+		ON contract_event (chain_id, contract, block_number)`,
+	// Redis Stream 投递状态：streamed_at 非空表示下游看到的是有效事件，加列前的事件会重新投递一次
+	`ALTER TABLE contract_event ADD COLUMN IF NOT EXISTS streamed_at TIMESTAMPTZ`,
+	`CREATE INDEX IF NOT EXISTS idx_contract_event_unstreamed
+		ON contract_event (chain_id, contract, block_number, log_index) WHERE streamed_at IS NULL AND NOT removed`,
+	`CREATE INDEX IF NOT EXISTS idx_contract_event_unretracted
+		ON contract_event (chain_id, contract, block_number, log_index) WHERE streamed_at IS NOT NULL AND removed`,
+	`CREATE TABLE IF NOT EXISTS dead_letter (
+		id            BIGSERIAL   PRIMARY KEY,
+		chain_id      BIGINT      NOT NULL,
+		contract      VARCHAR(42) NOT NULL,
+		tx_hash       VARCHAR(66) NOT NULL,
+		log_index     INT         NOT NULL,
+		block_number  BIGINT      NOT NULL,
+		event_name    VARCHAR(64) NOT NULL DEFAULT '',
+		raw_log       JSONB       NOT NULL,
+		error         TEXT        NOT NULL,
+		attempts      INT         NOT NULL DEFAULT 1,
+		status        VARCHAR(16) NOT NULL DEFAULT 'pending',
+		next_retry_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+		updated_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+		UNIQUE (chain_id, tx_hash, log_index)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_dead_letter_due
+		ON dead_letter (chain_id, contract, status, next_retry_at)`,
+	`CREATE TABLE IF NOT EXISTS multisig_application (
+		chain_id     BIGINT      NOT NULL,
+		contract     VARCHAR(42) NOT NULL,
+		msg_hash     VARCHAR(66) NOT NULL,
+		app_index    INT         NOT NULL,
+		applicant    VARCHAR(42) NOT NULL,
+		target       VARCHAR(42) NOT NULL,
+		threshold    INT         NOT NULL,
+		signatures   INT         NOT NULL DEFAULT 0,
+		status       VARCHAR(16) NOT NULL DEFAULT 'pending',
+		tx_hash      VARCHAR(66) NOT NULL,
+		log_index    INT         NOT NULL,
+		block_number BIGINT      NOT NULL,
+		created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+		updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (chain_id, tx_hash, log_index)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_multisig_application_hash
+		ON multisig_application (chain_id, contract, msg_hash, app_index)`,
+	`CREATE TABLE IF NOT EXISTS multisig_signature (
+		chain_id     BIGINT      NOT NULL,
+		contract     VARCHAR(42) NOT NULL,
+		msg_hash     VARCHAR(66) NOT NULL,
+		app_index    INT         NOT NULL,
+		signer       VARCHAR(42) NOT NULL,
+		signed       BOOLEAN     NOT NULL,
+		tx_hash      VARCHAR(66) NOT NULL,
+		log_index    INT         NOT NULL,
+		block_number BIGINT      NOT NULL,
+		updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (chain_id, contract, msg_hash, app_index, signer)
+	)`,
+	`CREATE TABLE IF NOT EXISTS debt_token (
+		chain_id   BIGINT      NOT NULL,
+		token      VARCHAR(42) NOT NULL,
+		pool       VARCHAR(42) NOT NULL,
+		pool_id    BIGINT      NOT NULL,
+		side       VARCHAR(2)  NOT NULL,
+		synced     BOOLEAN     NOT NULL DEFAULT false,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (chain_id, token)
+	)`,
+	`CREATE TABLE IF NOT EXISTS debt_token_transfer (
+		chain_id     BIGINT         NOT NULL,
+		tx_hash      VARCHAR(66)    NOT NULL,
+		log_index    INT            NOT NULL,
+		token        VARCHAR(42)    NOT NULL,
+		from_addr    VARCHAR(42)    NOT NULL,
+		to_addr      VARCHAR(42)    NOT NULL,
+		amount       NUMERIC(78, 0) NOT NULL,
+		block_number BIGINT         NOT NULL,
+		removed      BOOLEAN        NOT NULL DEFAULT false,
+		PRIMARY KEY (chain_id, tx_hash, log_index)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_debt_token_transfer_from
+		ON debt_token_transfer (chain_id, token, from_addr)`,
+	`CREATE INDEX IF NOT EXISTS idx_debt_token_transfer_to
+		ON debt_token_transfer (chain_id, token, to_addr)`,
+	`CREATE TABLE IF NOT EXISTS debt_token_balance (
+		chain_id   BIGINT         NOT NULL,
+		token      VARCHAR(42)    NOT NULL,
+		holder     VARCHAR(42)    NOT NULL,
+		pool_id    BIGINT         NOT NULL,
+		side       VARCHAR(2)     NOT NULL,
+		balance    NUMERIC(78, 0) NOT NULL,
+		updated_at TIMESTAMPTZ    NOT NULL DEFAULT now(),
+		PRIMARY KEY (chain_id, token, holder)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_debt_token_balance_pool
+		ON debt_token_balance (chain_id, pool_id, side)`,
+	`CREATE TABLE IF NOT EXISTS oracle_price (
+		chain_id     BIGINT         NOT NULL,
+		tx_hash      VARCHAR(66)    NOT NULL,
+		seq          INT            NOT NULL,
+		oracle       VARCHAR(42)    NOT NULL,
+		method       VARCHAR(32)    NOT NULL,
+		underlying   NUMERIC(78, 0) NOT NULL,
+		requested    NUMERIC(78, 0) NOT NULL,
+		asset        VARCHAR(42)    NOT NULL,
+		price        NUMERIC(78, 0) NOT NULL,
+		block_number BIGINT         NOT NULL,
+		block_time   TIMESTAMPTZ    NOT NULL,
+		PRIMARY KEY (chain_id, tx_hash, seq)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_oracle_price_underlying
+		ON oracle_price (chain_id, oracle, underlying, block_number)`,
+	`CREATE TABLE IF NOT EXISTS governance_change (
+		chain_id     BIGINT      NOT NULL,
+		contract     VARCHAR(42) NOT NULL,
+		param        VARCHAR(32) NOT NULL,
+		old_value    TEXT        NOT NULL,
+		new_value    TEXT        NOT NULL,
+		tx_hash      VARCHAR(66) NOT NULL,
+		log_index    INT         NOT NULL,
+		block_number BIGINT      NOT NULL,
+		block_time   TIMESTAMPTZ NOT NULL,
+		sender       VARCHAR(42) NOT NULL,
+		critical     BOOLEAN     NOT NULL DEFAULT false,
+		alerted_at   TIMESTAMPTZ,
+		created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (chain_id, tx_hash, log_index, param)
+	)`,
+	// 告警邮件发出后由 pledge-backend 填 alerted_at；加列前的记录视为已告警
+	`ALTER TABLE governance_change ADD COLUMN IF NOT EXISTS alerted_at TIMESTAMPTZ DEFAULT now()`,
+	`ALTER TABLE governance_change ALTER COLUMN alerted_at DROP DEFAULT`,
+	`CREATE INDEX IF NOT EXISTS idx_governance_change_param
+		ON governance_change (chain_id, contract, param, block_number)`,
+	`CREATE INDEX IF NOT EXISTS idx_governance_change_unalerted
+		ON governance_change (block_number, log_index) WHERE critical AND alerted_at IS NULL`,
+	`CREATE TABLE IF NOT EXISTS webhook (
+		id              BIGSERIAL   PRIMARY KEY,
+		url             TEXT        NOT NULL,
+		secret          TEXT        NOT NULL,
+		chain_id        BIGINT      NOT NULL DEFAULT 0,
+		events          TEXT[]      NOT NULL DEFAULT '{}',
+		pool_ids        BIGINT[]    NOT NULL DEFAULT '{}',
+		addresses       TEXT[]      NOT NULL DEFAULT '{}',
+		enabled         BOOLEAN     NOT NULL DEFAULT true,
+		failures        INT         NOT NULL DEFAULT 0,
+		disabled_reason TEXT        NOT NULL DEFAULT '',
+		created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+		updated_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhook_delivery (
+		id            BIGSERIAL   PRIMARY KEY,
+		webhook_id    BIGINT      NOT NULL,
+		chain_id      BIGINT      NOT NULL,
+		tx_hash       VARCHAR(66) NOT NULL,
+		log_index     INT         NOT NULL,
+		event_name    VARCHAR(64) NOT NULL,
+		payload       JSONB       NOT NULL,
+		removed       BOOLEAN     NOT NULL DEFAULT false,
+		status        VARCHAR(16) NOT NULL DEFAULT 'pending',
+		attempts      INT         NOT NULL DEFAULT 0,
+		response_code INT         NOT NULL DEFAULT 0,
+		error         TEXT        NOT NULL DEFAULT '',
+		next_retry_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+		updated_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`,
+	// 同一事件对同一 webhook 最多一条投递、一条撤回
+	`ALTER TABLE webhook_delivery ADD COLUMN IF NOT EXISTS removed BOOLEAN NOT NULL DEFAULT false`,
+	`ALTER TABLE webhook_delivery DROP CONSTRAINT IF EXISTS webhook_delivery_webhook_id_chain_id_tx_hash_log_index_key`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_delivery_event
+		ON webhook_delivery (webhook_id, chain_id, tx_hash, log_index, removed)`,
+	`CREATE INDEX IF NOT EXISTS idx_webhook_delivery_due
+		ON webhook_delivery (status, next_retry_at)`,
+}
+
+// 初始化数据库连接
+func InitDB(dsn string) error {
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return err
+	}
+
+	if err = db.Ping(); err != nil {
+		return err
+	}
+
+	for _, s := range schema {
+		if _, err = db.Exec(s); err != nil {
+			return err
+		}
+	}
+
+	DB = db
+	return nil
+}
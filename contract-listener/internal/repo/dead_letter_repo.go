@@ -0,0 +1,139 @@
+package repo
+
+import (
+	"contract-listener/internal/model"
+	"strings"
+	"time"
+)
+
+const deadLetterColumns = `id, chain_id, contract, tx_hash, log_index, block_number, event_name, raw_log,
+	error, attempts, status, next_retry_at, created_at, updated_at`
+
+// 写入死信：同一日志再次失败时累加次数、覆盖错误，重新进入待重试
+func SaveDeadLetter(d model.DeadLetter) error {
+
+	_, err := DB.Exec(
+		`INSERT INTO dead_letter
+			(chain_id, contract, tx_hash, log_index, block_number, event_name, raw_log, error, next_retry_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (chain_id, tx_hash, log_index) DO UPDATE
+		SET raw_log       = EXCLUDED.raw_log,
+			error         = EXCLUDED.error,
+			attempts      = dead_letter.attempts + 1,
+			status        = 'pending',
+			next_retry_at = EXCLUDED.next_retry_at,
+			updated_at    = now()`,
+		d.ChainID, strings.ToLower(d.Contract), d.TxHash, d.LogIndex, d.Block, d.Event,
+		[]byte(d.Log), d.Error, d.NextRetryAt,
+	)
+
+	return err
+}
+
+// 到期待重试的死信，按区块顺序
+func DueDeadLetters(chainID uint64, contract string, limit int) ([]model.DeadLetter, error) {
+
+	return queryDeadLetters(
+		`SELECT `+deadLetterColumns+` FROM dead_letter
+		WHERE chain_id = $1 AND contract = $2 AND status = 'pending' AND next_retry_at <= now()
+		ORDER BY block_number, log_index
+		LIMIT $3`,
+		chainID, strings.ToLower(contract), limit,
+	)
+}
+
+// 重试失败：记录错误和下次重试时间，次数用完置为 failed
+func RetryDeadLetterFailed(id int64, errMsg string, next time.Time, exhausted bool) error {
+
+	status := model.DeadLetterPending
+	if exhausted {
+		status = model.DeadLetterFailed
+	}
+
+	_, err := DB.Exec(
+		`UPDATE dead_letter
+		SET error = $2, attempts = attempts + 1, status = $3, next_retry_at = $4, updated_at = now()
+		WHERE id = $1`,
+		id, errMsg, status, next,
+	)
+
+	return err
+}
+
+// 重试成功
+func MarkDeadLetterDone(id int64) error {
+
+	_, err := DB.Exec(
+		`UPDATE dead_letter SET status = 'done', updated_at = now() WHERE id = $1`,
+		id,
+	)
+
+	return err
+}
+
+// 按状态列出死信（status 为空列出全部），最新的在前
+func ListDeadLetters(status string, limit int) ([]model.DeadLetter, error) {
+
+	if status == "" {
+		return queryDeadLetters(
+			`SELECT `+deadLetterColumns+` FROM dead_letter ORDER BY id DESC LIMIT $1`,
+			limit,
+		)
+	}
+
+	return queryDeadLetters(
+		`SELECT `+deadLetterColumns+` FROM dead_letter WHERE status = $1 ORDER BY id DESC LIMIT $2`,
+		status, limit,
+	)
+}
+
+// 人工重新入队：次数清零，立即重试
+// id 为 0 时重新入队全部 failed 的死信
+func RequeueDeadLetters(id int64) (int64, error) {
+
+	query := `UPDATE dead_letter
+		SET attempts = 0, status = 'pending', next_retry_at = now(), updated_at = now()
+		WHERE id = $1 AND status <> 'pending'`
+	args := []any{id}
+
+	if id == 0 {
+		query = `UPDATE dead_letter
+		SET attempts = 0, status = 'pending', next_retry_at = now(), updated_at = now()
+		WHERE status = 'failed'`
+		args = nil
+	}
+
+	res, err := DB.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func queryDeadLetters(query string, args ...any) ([]model.DeadLetter, error) {
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.DeadLetter
+	for rows.Next() {
+
+		var d model.DeadLetter
+		var raw []byte
+
+		err := rows.Scan(&d.ID, &d.ChainID, &d.Contract, &d.TxHash, &d.LogIndex, &d.Block, &d.Event, &raw,
+			&d.Error, &d.Attempts, &d.Status, &d.NextRetryAt, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		d.Log = raw
+		list = append(list, d)
+	}
+
+	return list, rows.Err()
+}
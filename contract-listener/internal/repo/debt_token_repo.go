@@ -0,0 +1,186 @@
+package repo
+
+import (
+	"contract-listener/internal/model"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// 已发现的 DebtToken，synced=false 表示还没从起始区块补扫过
+func GetDebtTokens(chainID uint64, pool string) (map[string]bool, error) {
+
+	rows, err := DB.Query(
+		`SELECT token, synced FROM debt_token WHERE chain_id = $1 AND pool = $2`,
+		chainID, strings.ToLower(pool),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make(map[string]bool)
+	for rows.Next() {
+		var token string
+		var synced bool
+		if err := rows.Scan(&token, &synced); err != nil {
+			return nil, err
+		}
+		tokens[token] = synced
+	}
+
+	return tokens, rows.Err()
+}
+
+// 登记新发现的 DebtToken，已有的不覆盖
+func SaveDebtToken(t model.DebtToken) error {
+
+	_, err := DB.Exec(
+		`INSERT INTO debt_token (chain_id, token, pool, pool_id, side)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (chain_id, token) DO NOTHING`,
+		t.ChainID, strings.ToLower(t.Token), strings.ToLower(t.Pool), t.PoolID, t.Side,
+	)
+
+	return err
+}
+
+// 补扫完成
+func MarkDebtTokensSynced(chainID uint64, tokens []string) error {
+
+	for i := range tokens {
+		tokens[i] = strings.ToLower(tokens[i])
+	}
+
+	_, err := DB.Exec(
+		`UPDATE debt_token SET synced = true WHERE chain_id = $1 AND token = ANY($2)`,
+		chainID, pq.Array(tokens),
+	)
+
+	return err
+}
+
+// 幂等写入转账；之前被撤销的同一日志恢复
+func SaveDebtTransfer(t model.DebtTransfer) error {
+
+	_, err := DB.Exec(
+		`INSERT INTO debt_token_transfer
+			(chain_id, tx_hash, log_index, token, from_addr, to_addr, amount, block_number)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (chain_id, tx_hash, log_index) DO UPDATE
+		SET block_number = EXCLUDED.block_number, removed = false`,
+		t.ChainID, t.TxHash, t.LogIndex, strings.ToLower(t.Token),
+		strings.ToLower(t.From), strings.ToLower(t.To), t.Amount.String(), t.Block,
+	)
+
+	return err
+}
+
+// 撤销转账：日志所在区块被重组掉
+func RemoveDebtTransfer(chainID uint64, txHash string, logIndex uint) error {
+
+	_, err := DB.Exec(
+		`UPDATE debt_token_transfer SET removed = true
+		WHERE chain_id = $1 AND tx_hash = $2 AND log_index = $3`,
+		chainID, txHash, logIndex,
+	)
+
+	return err
+}
+
+// 检查点回退到分叉点时撤销之后的转账，返回受影响的 (代币, 持有人) 用于重算余额
+func RemoveDebtTransfersAfter(chainID uint64, tokens []string, block uint64) ([][2]string, error) {
+
+	for i := range tokens {
+		tokens[i] = strings.ToLower(tokens[i])
+	}
+
+	rows, err := DB.Query(
+		`WITH gone AS (
+			UPDATE debt_token_transfer SET removed = true
+			WHERE chain_id = $1 AND token = ANY($2) AND block_number > $3 AND NOT removed
+			RETURNING token, from_addr, to_addr
+		)
+		SELECT token, from_addr FROM gone
+		UNION
+		SELECT token, to_addr FROM gone`,
+		chainID, pq.Array(tokens), block,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holders [][2]string
+	for rows.Next() {
+		var h [2]string
+		if err := rows.Scan(&h[0], &h[1]); err != nil {
+			return nil, err
+		}
+		holders = append(holders, h)
+	}
+
+	return holders, rows.Err()
+}
+
+// 按未撤销的转账重算持有人余额（幂等，乱序、重放、重组后都能得到正确结果）
+// 转账按 合约+from 分区，转给同一持有人的两笔可能在不同 worker 上同时重算，
+// 按 (链, 代币, 持有人) 加事务级锁串行化，后提交的一次一定能看到两笔转账
+func RefreshDebtBalance(chainID uint64, token, holder string) error {
+
+	token, holder = strings.ToLower(token), strings.ToLower(holder)
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`SELECT pg_advisory_xact_lock(hashtextextended($1::text || ':' || $2 || ':' || $3, 0))`,
+		chainID, token, holder,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO debt_token_balance (chain_id, token, holder, pool_id, side, balance)
+		SELECT d.chain_id, d.token, $3, d.pool_id, d.side,
+			COALESCE((SELECT sum(amount) FROM debt_token_transfer
+				WHERE chain_id = $1 AND token = $2 AND to_addr = $3 AND NOT removed), 0)
+			- COALESCE((SELECT sum(amount) FROM debt_token_transfer
+				WHERE chain_id = $1 AND token = $2 AND from_addr = $3 AND NOT removed), 0)
+		FROM debt_token d WHERE d.chain_id = $1 AND d.token = $2
+		ON CONFLICT (chain_id, token, holder) DO UPDATE
+		SET balance = EXCLUDED.balance, updated_at = now()`,
+		chainID, token, holder,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// 代币所属池子的 pid，ok=false 表示不是已发现的 DebtToken
+func GetDebtTokenPool(chainID uint64, token string) (int64, bool, error) {
+
+	var pid int64
+
+	err := DB.QueryRow(
+		`SELECT pool_id FROM debt_token WHERE chain_id = $1 AND token = $2`,
+		chainID, strings.ToLower(token),
+	).Scan(&pid)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return pid, true, nil
+}
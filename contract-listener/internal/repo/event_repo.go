@@ -2,18 +2,112 @@ package repo
 
 import (
 	"contract-listener/internal/model"
-	"sync"
+	"strings"
+	"time"
 )
 
-var m sync.Map
+// 幂等写入：同一 (chain_id, tx_hash, log_index) 只写一次
+// 之前被撤销、或者重组后落在新区块里的同一日志会被覆盖，并重新投递到 Stream
+// inserted=false 表示已经处理过
+func SaveEvent(e model.Event) (bool, error) {
 
-// 幂等检查
-func Exists(tx string, idx uint) bool {
+	res, err := DB.Exec(
+		`INSERT INTO contract_event
+			(chain_id, tx_hash, log_index, block_number, block_hash, block_time, contract, event_name, args)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (chain_id, tx_hash, log_index) DO UPDATE
+		SET block_number = EXCLUDED.block_number,
+			block_hash   = EXCLUDED.block_hash,
+			block_time   = EXCLUDED.block_time,
+			args         = EXCLUDED.args,
+			removed      = false,
+			streamed_at  = NULL
+		WHERE contract_event.removed OR contract_event.block_hash <> EXCLUDED.block_hash`,
+		e.ChainID, e.TxHash, e.LogIndex, e.Block, e.BlockHash, e.BlockTime,
+		strings.ToLower(e.Contract), e.Name, []byte(e.Args),
+	)
+	if err != nil {
+		return false, err
+	}
 
-	_, ok := m.Load(tx + string(rune(idx)))
-	return ok
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// 撤销：日志所在区块被重组掉
+func MarkEventRemoved(chainID uint64, txHash string, logIndex uint, blockHash string) error {
+
+	_, err := DB.Exec(
+		`UPDATE contract_event SET removed = true
+		WHERE chain_id = $1 AND tx_hash = $2 AND log_index = $3 AND block_hash = $4`,
+		chainID, txHash, logIndex, blockHash,
+	)
+
+	return err
+}
+
+// 检查点回退到分叉点时，分叉点之后的事件全部标记撤销
+func MarkEventsRemovedAfter(chainID uint64, contract string, block uint64) error {
+
+	_, err := DB.Exec(
+		`UPDATE contract_event SET removed = true
+		WHERE chain_id = $1 AND contract = $2 AND block_number > $3 AND NOT removed`,
+		chainID, strings.ToLower(contract), block,
+	)
+
+	return err
 }
 
-func Save(e model.Event) {
-	m.Store(e.TxHash+string(rune(e.LogIndex)), true)
+// 合约还要投递到 Stream 的事件，按区块和日志序号排序：
+// 没投递过的有效事件（只取 to 区块及之前的），和投递过、之后被撤销的事件（需要投递撤回）
+func GetUnstreamedEvents(chainID uint64, contract string, to uint64, limit int) ([]model.Event, error) {
+
+	rows, err := DB.Query(
+		`SELECT tx_hash, log_index, block_number, block_hash, block_time, contract, event_name, args, removed
+		FROM contract_event
+		WHERE chain_id = $1 AND contract = $2
+			AND ((streamed_at IS NULL AND NOT removed AND block_number <= $3) OR (streamed_at IS NOT NULL AND removed))
+		ORDER BY block_number, log_index
+		LIMIT $4`,
+		chainID, strings.ToLower(contract), to, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []model.Event
+	for rows.Next() {
+		e := model.Event{ChainID: chainID}
+		var args []byte
+		if err := rows.Scan(&e.TxHash, &e.LogIndex, &e.Block, &e.BlockHash, &e.BlockTime, &e.Contract, &e.Name, &args, &e.Removed); err != nil {
+			return nil, err
+		}
+		e.Args = args
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// 记录投递结果：投递了事件填 streamed_at，投递了撤回清空
+// 按区块哈希匹配，投递期间事件被重新写入（重组后落到新区块）的不更新，下一轮按新内容重新投递
+func MarkEventStreamed(e model.Event) error {
+
+	var streamedAt any
+	if !e.Removed {
+		streamedAt = time.Now().UTC()
+	}
+
+	_, err := DB.Exec(
+		`UPDATE contract_event SET streamed_at = $5
+		WHERE chain_id = $1 AND tx_hash = $2 AND log_index = $3 AND block_hash = $4`,
+		e.ChainID, e.TxHash, e.LogIndex, e.BlockHash, streamedAt,
+	)
+
+	return err
 }
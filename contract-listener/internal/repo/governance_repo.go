@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"contract-listener/internal/model"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// 幂等写入参数变更，inserted=false 表示已经记录过（重放、死信重试）
+func SaveGovernanceChange(g model.GovernanceChange) (bool, error) {
+
+	res, err := DB.Exec(
+		`INSERT INTO governance_change
+			(chain_id, contract, param, old_value, new_value, tx_hash, log_index, block_number, block_time, sender, critical)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (chain_id, tx_hash, log_index, param) DO NOTHING`,
+		g.ChainID, strings.ToLower(g.Contract), g.Param, g.Old, g.New,
+		g.TxHash, g.LogIndex, g.Block, g.BlockTime, strings.ToLower(g.Sender), g.Critical,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// (block, logIndex) 之前最近一次变更后的值，ok=false 表示之前没有变更过
+func LastGovernanceValue(chainID uint64, contract, param string, block uint64, logIndex uint) (string, bool, error) {
+
+	var v string
+
+	err := DB.QueryRow(
+		`SELECT new_value FROM governance_change
+		WHERE chain_id = $1 AND contract = $2 AND param = $3 AND (block_number, log_index) < ($4, $5)
+		ORDER BY block_number DESC, log_index DESC LIMIT 1`,
+		chainID, strings.ToLower(contract), param, block, logIndex,
+	).Scan(&v)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return v, true, nil
+}
@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"contract-listener/internal/model"
+	"strings"
+)
+
+// 写入申请，返回它在 signatureMap[msgHash] 里的下标
+// 同一 msgHash 的申请人相同，worker 按 合约+申请人 分区，这里按到达顺序计数即是链上顺序
+func SaveApplication(a model.MultisigApplication) (uint64, error) {
+
+	var index uint64
+
+	err := DB.QueryRow(
+		`INSERT INTO multisig_application
+			(chain_id, contract, msg_hash, app_index, applicant, target, threshold, tx_hash, log_index, block_number)
+		VALUES ($1, $2, $3, (
+			SELECT count(*) FROM multisig_application
+			WHERE chain_id = $1 AND contract = $2 AND msg_hash = $3
+				AND (block_number, log_index) < ($9, $8)
+		), $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (chain_id, tx_hash, log_index) DO UPDATE
+		SET threshold = EXCLUDED.threshold, updated_at = now()
+		RETURNING app_index`,
+		a.ChainID, strings.ToLower(a.Contract), a.MsgHash,
+		strings.ToLower(a.Applicant), strings.ToLower(a.Target), a.Threshold,
+		a.TxHash, a.LogIndex, a.Block,
+	).Scan(&index)
+
+	return index, err
+}
+
+// 记录签名人的最新操作，乱序到达的旧操作不会覆盖新操作
+func SaveSignature(s model.MultisigSignature) error {
+
+	_, err := DB.Exec(
+		`INSERT INTO multisig_signature
+			(chain_id, contract, msg_hash, app_index, signer, signed, tx_hash, log_index, block_number)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (chain_id, contract, msg_hash, app_index, signer) DO UPDATE
+		SET signed       = EXCLUDED.signed,
+			tx_hash      = EXCLUDED.tx_hash,
+			log_index    = EXCLUDED.log_index,
+			block_number = EXCLUDED.block_number,
+			updated_at   = now()
+		WHERE (multisig_signature.block_number, multisig_signature.log_index)
+			<= (EXCLUDED.block_number, EXCLUDED.log_index)`,
+		s.ChainID, strings.ToLower(s.Contract), s.MsgHash, s.Index,
+		strings.ToLower(s.Signer), s.Signed, s.TxHash, s.LogIndex, s.Block,
+	)
+
+	return err
+}
+
+// 重新统计申请的有效签名数并更新状态
+// 签名和申请在不同分区处理，先到的签名在申请入库后由这里补算
+func RefreshApplication(chainID uint64, contract, msgHash string, index uint64) error {
+
+	_, err := DB.Exec(
+		`UPDATE multisig_application a
+		SET signatures = s.signed,
+			status = CASE
+				WHEN a.threshold > 0 AND s.signed >= a.threshold THEN $5
+				WHEN s.signed = 0 AND s.revoked > 0 THEN $6
+				ELSE $7
+			END,
+			updated_at = now()
+		FROM (
+			SELECT count(*) FILTER (WHERE signed)     AS signed,
+				   count(*) FILTER (WHERE NOT signed) AS revoked
+			FROM multisig_signature
+			WHERE chain_id = $1 AND contract = $2 AND msg_hash = $3 AND app_index = $4
+		) s
+		WHERE a.chain_id = $1 AND a.contract = $2 AND a.msg_hash = $3 AND a.app_index = $4`,
+		chainID, strings.ToLower(contract), msgHash, index,
+		model.MultisigApproved, model.MultisigRevoked, model.MultisigPending,
+	)
+
+	return err
+}
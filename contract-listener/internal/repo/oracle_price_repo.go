@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"contract-listener/internal/model"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 幂等写入喂价记录
+func SaveOraclePrice(p model.OraclePrice) error {
+
+	_, err := DB.Exec(
+		`INSERT INTO oracle_price
+			(chain_id, tx_hash, seq, oracle, method, underlying, requested, asset, price, block_number, block_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (chain_id, tx_hash, seq) DO NOTHING`,
+		p.ChainID, p.TxHash, p.Seq, strings.ToLower(p.Oracle), p.Method,
+		p.Underlying.String(), p.Requested.String(),
+		strings.ToLower(common.BigToAddress(p.Requested).Hex()),
+		p.Price.String(), p.Block, p.BlockTime,
+	)
+
+	return err
+}
+
+// calldata 检查点回退到分叉点时删除之后由交易解析出的记录（喂价、setPause）
+func DeleteCallsAfter(chainID uint64, contract string, block uint64) error {
+
+	contract = strings.ToLower(contract)
+
+	_, err := DB.Exec(
+		`DELETE FROM oracle_price WHERE chain_id = $1 AND oracle = $2 AND block_number > $3`,
+		chainID, contract, block,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(
+		`DELETE FROM governance_change WHERE chain_id = $1 AND contract = $2 AND param = 'paused' AND block_number > $3`,
+		chainID, contract, block,
+	)
+
+	return err
+}
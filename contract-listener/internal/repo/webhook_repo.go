@@ -0,0 +1,207 @@
+package repo
+
+import (
+	"contract-listener/internal/model"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// 启用中的 webhook
+func GetWebhooks() ([]model.Webhook, error) {
+
+	rows, err := DB.Query(
+		`SELECT id, url, secret, chain_id, events, pool_ids, addresses, created_at
+		FROM webhook WHERE enabled ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []model.Webhook
+	for rows.Next() {
+		var w model.Webhook
+		err := rows.Scan(&w.ID, &w.URL, &w.Secret, &w.ChainID,
+			pq.Array(&w.Events), pq.Array(&w.PoolIDs), pq.Array(&w.Addresses), &w.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		for i, a := range w.Addresses {
+			w.Addresses[i] = strings.ToLower(a)
+		}
+		hooks = append(hooks, w)
+	}
+
+	return hooks, rows.Err()
+}
+
+// 幂等写入待投递记录：同一事件对同一 webhook 只投递一次
+// 重组后事件换了区块、或者已经发过撤回，重新投递；还没发出的撤回直接取消
+func SaveWebhookDelivery(d model.WebhookDelivery) error {
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`DELETE FROM webhook_delivery
+		WHERE webhook_id = $1 AND chain_id = $2 AND tx_hash = $3 AND log_index = $4 AND removed AND status = 'pending'`,
+		d.WebhookID, d.ChainID, d.TxHash, d.LogIndex,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO webhook_delivery (webhook_id, chain_id, tx_hash, log_index, event_name, payload)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (webhook_id, chain_id, tx_hash, log_index, removed) DO UPDATE
+		SET payload = EXCLUDED.payload, status = 'pending', attempts = 0, response_code = 0, error = '',
+			next_retry_at = now(), updated_at = now()
+		WHERE webhook_delivery.payload->>'block_hash' <> EXCLUDED.payload->>'block_hash'
+			OR EXISTS (SELECT 1 FROM webhook_delivery r
+				WHERE r.webhook_id = $1 AND r.chain_id = $2 AND r.tx_hash = $3 AND r.log_index = $4 AND r.removed)`,
+		d.WebhookID, d.ChainID, d.TxHash, d.LogIndex, d.Event, []byte(d.Payload),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// 写入撤回：请求体和原投递相同，只是 removed 为 true；原投递失败的也发，对方可能已经收到
+// 撤回过又重新投递（原投递比撤回更新）的事件再次被撤销时，重新发一次撤回
+const insertWebhookRetracts = `INSERT INTO webhook_delivery (webhook_id, chain_id, tx_hash, log_index, event_name, payload, removed)
+	SELECT webhook_id, chain_id, tx_hash, log_index, event_name, jsonb_set(payload, '{removed}', 'true'), true
+	FROM webhook_delivery
+	WHERE %s
+	ON CONFLICT (webhook_id, chain_id, tx_hash, log_index, removed) DO UPDATE
+	SET payload = EXCLUDED.payload, status = 'pending', attempts = 0, response_code = 0, error = '',
+		next_retry_at = now(), updated_at = now()
+	WHERE EXISTS (SELECT 1 FROM webhook_delivery o
+		WHERE o.webhook_id = webhook_delivery.webhook_id AND o.chain_id = webhook_delivery.chain_id
+			AND o.tx_hash = webhook_delivery.tx_hash AND o.log_index = webhook_delivery.log_index
+			AND NOT o.removed AND o.updated_at > webhook_delivery.updated_at)`
+
+// 事件所在区块被重组掉：给写入过这个事件（同一区块哈希）的 webhook 写入撤回
+func SaveWebhookRetracts(chainID uint64, txHash string, logIndex uint, blockHash string) error {
+
+	_, err := DB.Exec(
+		fmt.Sprintf(insertWebhookRetracts,
+			`chain_id = $1 AND tx_hash = $2 AND log_index = $3 AND NOT removed AND payload->>'block_hash' = $4`),
+		chainID, txHash, logIndex, blockHash,
+	)
+
+	return err
+}
+
+// 检查点回退到分叉点：合约在 block 之后的事件全部写入撤回，重放时仍在主链上的会重新投递
+func SaveWebhookRetractsAfter(chainID uint64, contract string, block uint64) error {
+
+	_, err := DB.Exec(
+		fmt.Sprintf(insertWebhookRetracts,
+			`chain_id = $1 AND NOT removed AND lower(payload->>'contract') = $2 AND (payload->>'block')::bigint > $3`),
+		chainID, strings.ToLower(contract), block,
+	)
+
+	return err
+}
+
+// 到期待投递的记录（webhook 仍启用），先进先出
+// 撤回要等原投递有了结果（成功或重试用完）再发，不会比原事件先到
+func DueWebhookDeliveries(limit int) ([]model.WebhookDelivery, error) {
+
+	rows, err := DB.Query(
+		`SELECT d.id, d.webhook_id, d.chain_id, d.tx_hash, d.log_index, d.event_name, d.payload, d.removed,
+			d.attempts, d.status, d.next_retry_at, w.url, w.secret
+		FROM webhook_delivery d JOIN webhook w ON w.id = d.webhook_id
+		WHERE d.status = 'pending' AND d.next_retry_at <= now() AND w.enabled
+			AND NOT (d.removed AND EXISTS (
+				SELECT 1 FROM webhook_delivery o
+				WHERE o.webhook_id = d.webhook_id AND o.chain_id = d.chain_id AND o.tx_hash = d.tx_hash
+					AND o.log_index = d.log_index AND NOT o.removed AND o.status = 'pending'))
+		ORDER BY d.id
+		LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []model.WebhookDelivery
+	for rows.Next() {
+		var d model.WebhookDelivery
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.ChainID, &d.TxHash, &d.LogIndex, &d.Event, &payload, &d.Removed,
+			&d.Attempts, &d.Status, &d.NextRetryAt, &d.URL, &d.Secret)
+		if err != nil {
+			return nil, err
+		}
+		d.Payload = payload
+		list = append(list, d)
+	}
+
+	return list, rows.Err()
+}
+
+// 投递成功，webhook 连续失败次数清零
+func MarkWebhookDelivered(d model.WebhookDelivery, code int) error {
+
+	_, err := DB.Exec(
+		`UPDATE webhook_delivery
+		SET status = 'delivered', attempts = attempts + 1, response_code = $2, error = '', updated_at = now()
+		WHERE id = $1`,
+		d.ID, code,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = DB.Exec(
+		`UPDATE webhook SET failures = 0, updated_at = now() WHERE id = $1 AND failures > 0`,
+		d.WebhookID,
+	)
+
+	return err
+}
+
+// 投递失败：记录错误和下次重试时间，次数用完置为 failed
+// webhook 连续失败达到 maxFailures 次自动停用，返回是否因此停用
+func MarkWebhookFailed(d model.WebhookDelivery, code int, errMsg string, next time.Time, exhausted bool, maxFailures int) (bool, error) {
+
+	status := model.WebhookPending
+	if exhausted {
+		status = model.WebhookFailed
+	}
+
+	_, err := DB.Exec(
+		`UPDATE webhook_delivery
+		SET status = $2, attempts = attempts + 1, response_code = $3, error = $4, next_retry_at = $5, updated_at = now()
+		WHERE id = $1`,
+		d.ID, status, code, errMsg, next,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	var disabled bool
+	err = DB.QueryRow(
+		`UPDATE webhook
+		SET failures = failures + 1,
+			enabled = enabled AND failures + 1 < $2,
+			disabled_reason = CASE WHEN enabled AND failures + 1 >= $2 THEN $3 ELSE disabled_reason END,
+			updated_at = now()
+		WHERE id = $1
+		RETURNING failures = $2`,
+		d.WebhookID, maxFailures, "too many failures: "+errMsg,
+	).Scan(&disabled)
+
+	return disabled, err
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 一次直接发给合约、执行成功的交易调用（不发事件的方法从 calldata 解析）
+type Call struct {
+	ChainID   uint64
+	Contract  common.Address
+	TxHash    common.Hash
+	TxIndex   uint // 交易在区块内的序号
+	From      common.Address
+	Method    *abi.Method
+	Args      []any
+	Block     uint64
+	BlockTime time.Time
+}
+
+// 各合约需要按 calldata 处理的方法，key 为配置里的合约名
+var calls = map[string]map[string]func(Call) error{
+	"BscPledgeOracle": {
+		"setPrice":           handleOraclePrice,
+		"setPrices":          handleOraclePrice,
+		"setUnderlyingPrice": handleOraclePrice,
+	},
+	"PledgePool": {
+		"setPause": handleSetPause,
+	},
+}
+
+// 合约的 calldata 处理函数，方法名 -> 处理函数
+func CallHandlers(contract string) map[string]func(Call) error {
+	return calls[contract]
+}
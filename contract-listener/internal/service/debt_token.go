@@ -0,0 +1,101 @@
+package service
+
+import (
+	"contract-listener/internal/model"
+	"contract-listener/internal/notify"
+	"contract-listener/internal/repo"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DebtToken（ERC20）转账，铸造、销毁也走 Transfer
+type Transfer struct {
+	From  common.Address
+	To    common.Address
+	Value *big.Int
+}
+
+// 注册 DebtToken 事件：Transfer 维护持有人余额，Approval 只入库
+func registerDebtToken(r *Registry) {
+
+	register(r, "Transfer", r.HandleTransfer)
+
+	if ev, ok := r.abi.Events["Transfer"]; ok {
+		r.undo[ev.ID] = r.undoTransfer
+	}
+	r.rewind = r.rewindTransfers
+}
+
+func (r *Registry) HandleTransfer(l types.Log, e *Transfer) error {
+	log.Println("Transfer:", l.BlockNumber, l.Address.Hex(), e.From.Hex(), e.To.Hex(), e.Value)
+
+	err := repo.SaveDebtTransfer(model.DebtTransfer{
+		ChainID:  r.chainID,
+		Token:    l.Address.Hex(),
+		From:     e.From.Hex(),
+		To:       e.To.Hex(),
+		Amount:   e.Value,
+		TxHash:   l.TxHash.Hex(),
+		LogIndex: l.Index,
+		Block:    l.BlockNumber,
+	})
+	if err != nil {
+		return err
+	}
+
+	return r.refreshBalances(l.Address, e.From, e.To)
+}
+
+// 被重组掉的转账：撤销后重算双方余额
+func (r *Registry) undoTransfer(l types.Log) error {
+
+	e := new(Transfer)
+	if err := r.decode(r.abi.Events["Transfer"], l, e); err != nil {
+		return err
+	}
+
+	if err := repo.RemoveDebtTransfer(r.chainID, l.TxHash.Hex(), l.Index); err != nil {
+		return err
+	}
+
+	return r.refreshBalances(l.Address, e.From, e.To)
+}
+
+func (r *Registry) rewindTransfers(block uint64, addrs []common.Address) error {
+
+	tokens := make([]string, len(addrs))
+	for i, a := range addrs {
+		tokens[i] = a.Hex()
+	}
+
+	holders, err := repo.RemoveDebtTransfersAfter(r.chainID, tokens, block)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range holders {
+		if err := r.refreshBalances(common.HexToAddress(h[0]), common.HexToAddress(h[1])); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 零地址是铸造 / 销毁的对手方，不记余额；余额变了，pledge-backend 缓存的持仓一并失效
+func (r *Registry) refreshBalances(token common.Address, holders ...common.Address) error {
+
+	for _, h := range holders {
+		if h == (common.Address{}) {
+			continue
+		}
+		if err := repo.RefreshDebtBalance(r.chainID, token.Hex(), h.Hex()); err != nil {
+			return err
+		}
+	}
+
+	return notify.InvalidatePositions(r.chainID, holders...)
+}
@@ -2,41 +2,131 @@ package service
 
 import (
 	"log"
-	"math/big"
 
-	"github.com/ethereum/go-ethereum/accounts/abi"
-	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
-type DepositLend struct {
-	From       common.Address
-	Token      common.Address
-	Amount     *big.Int
-	MintAmount *big.Int
+// 注册 PledgePool 全部事件
+func registerPledgePool(r *Registry) {
+
+	// 出借生命周期
+	register(r, "DepositLend", r.HandleDepositLend)
+	register(r, "RefundLend", r.HandleRefundLend)
+	register(r, "ClaimLend", r.HandleClaimLend)
+	register(r, "WithdrawLend", r.HandleWithdrawLend)
+	register(r, "EmergencyLendWithdrawal", r.HandleEmergencyLendWithdrawal)
+
+	// 借款生命周期
+	register(r, "DepositBorrow", r.HandleDepositBorrow)
+	register(r, "RefundBorrow", r.HandleRefundBorrow)
+	register(r, "ClaimBorrow", r.HandleClaimBorrow)
+	register(r, "WithdrawBorrow", r.HandleWithdrawBorrow)
+	register(r, "EmergencyBorrowWithdrawal", r.HandleEmergencyBorrowWithdrawal)
+
+	// 池子状态 / 兑换
+	register(r, "StateChange", r.HandleStateChange)
+	register(r, "Swap", r.HandleSwap)
+	register(r, "Redeem", r.HandleRedeem)
+
+	// 管理参数
+	register(r, "SetFee", r.HandleSetFee)
+	register(r, "SetSwapRouterAddress", r.HandleSetSwapRouterAddress)
+	register(r, "SetFeeAddress", r.HandleSetFeeAddress)
+	register(r, "SetMinAmount", r.HandleSetMinAmount)
+	register(r, "OwnershipTransferred", r.HandleOwnershipTransferred)
+}
+
+func (r *Registry) HandleDepositLend(l types.Log, e *DepositLend) error {
+	log.Println("DepositLend:", l.BlockNumber, e.From.Hex(), e.Token.Hex(), e.Amount, e.MintAmount)
+	return r.refreshPool(l, nil, e.From)
+}
+
+func (r *Registry) HandleRefundLend(l types.Log, e *RefundLend) error {
+	log.Println("RefundLend:", l.BlockNumber, e.From.Hex(), e.Token.Hex(), e.Refund)
+	return r.refreshPool(l, nil, e.From)
+}
+
+func (r *Registry) HandleClaimLend(l types.Log, e *ClaimLend) error {
+	log.Println("ClaimLend:", l.BlockNumber, e.From.Hex(), e.Token.Hex(), e.Amount)
+	return r.refreshPool(l, nil, e.From)
+}
+
+func (r *Registry) HandleWithdrawLend(l types.Log, e *WithdrawLend) error {
+	log.Println("WithdrawLend:", l.BlockNumber, e.From.Hex(), e.Token.Hex(), e.Amount, e.BurnAmount)
+	return r.refreshPool(l, nil, e.From)
+}
+
+func (r *Registry) HandleEmergencyLendWithdrawal(l types.Log, e *EmergencyLendWithdrawal) error {
+	log.Println("EmergencyLendWithdrawal:", l.BlockNumber, e.From.Hex(), e.Token.Hex(), e.Amount)
+	return r.refreshPool(l, nil, e.From)
+}
+
+func (r *Registry) HandleDepositBorrow(l types.Log, e *DepositBorrow) error {
+	log.Println("DepositBorrow:", l.BlockNumber, e.From.Hex(), e.Token.Hex(), e.Amount, e.MintAmount)
+	return r.refreshPool(l, nil, e.From)
+}
+
+func (r *Registry) HandleRefundBorrow(l types.Log, e *RefundBorrow) error {
+	log.Println("RefundBorrow:", l.BlockNumber, e.From.Hex(), e.Token.Hex(), e.Refund)
+	return r.refreshPool(l, nil, e.From)
 }
 
-func HandleDeposit(a abi.ABI, logg types.Log) {
+func (r *Registry) HandleClaimBorrow(l types.Log, e *ClaimBorrow) error {
+	log.Println("ClaimBorrow:", l.BlockNumber, e.From.Hex(), e.Token.Hex(), e.Amount)
+	return r.refreshPool(l, nil, e.From)
+}
 
-	// topic[0] already matched
+func (r *Registry) HandleWithdrawBorrow(l types.Log, e *WithdrawBorrow) error {
+	log.Println("WithdrawBorrow:", l.BlockNumber, e.From.Hex(), e.Token.Hex(), e.Amount, e.BurnAmount)
+	return r.refreshPool(l, nil, e.From)
+}
 
-	var e DepositLend
+func (r *Registry) HandleEmergencyBorrowWithdrawal(l types.Log, e *EmergencyBorrowWithdrawal) error {
+	log.Println("EmergencyBorrowWithdrawal:", l.BlockNumber, e.From.Hex(), e.Token.Hex(), e.Amount)
+	return r.refreshPool(l, nil, e.From)
+}
 
-	// indexed参数在topics里
-	e.From = common.HexToAddress(logg.Topics[1].Hex())
-	e.Token = common.HexToAddress(logg.Topics[2].Hex())
+func (r *Registry) HandleStateChange(l types.Log, e *StateChange) error {
+	log.Println("StateChange:", l.BlockNumber, "pool", e.Pid, e.BeforeState, "->", e.AfterState)
+	return r.refreshPool(l, e.Pid)
+}
+
+func (r *Registry) HandleSwap(l types.Log, e *Swap) error {
+	log.Println("Swap:", l.BlockNumber, e.FromCoin.Hex(), e.ToCoin.Hex(), e.FromValue, e.ToValue)
+	return nil
+}
+
+func (r *Registry) HandleRedeem(l types.Log, e *Redeem) error {
+	log.Println("Redeem:", l.BlockNumber, e.Recieptor.Hex(), e.Token.Hex(), e.Amount)
+	return nil
+}
 
-	// 非indexed在data
-	err := a.UnpackIntoInterface(&e, "DepositLend", logg.Data)
-	if err != nil {
-		log.Println("decode fail", err)
-		return
+func (r *Registry) HandleSetFee(l types.Log, e *SetFee) error {
+	log.Println("SetFee:", l.BlockNumber, e.NewLendFee, e.NewBorrowFee)
+
+	// 事件不带旧值，取上一次变更的值（初始为 0）
+	if err := r.governance(l, "lend_fee", "", e.NewLendFee.String(), "0"); err != nil {
+		return err
 	}
+	return r.governance(l, "borrow_fee", "", e.NewBorrowFee.String(), "0")
+}
+
+func (r *Registry) HandleSetSwapRouterAddress(l types.Log, e *SetSwapRouterAddress) error {
+	log.Println("SetSwapRouterAddress:", l.BlockNumber, e.OldSwapAddress.Hex(), "->", e.NewSwapAddress.Hex())
+	return r.governance(l, "swap_router", e.OldSwapAddress.Hex(), e.NewSwapAddress.Hex(), "")
+}
+
+func (r *Registry) HandleSetFeeAddress(l types.Log, e *SetFeeAddress) error {
+	log.Println("SetFeeAddress:", l.BlockNumber, e.OldFeeAddress.Hex(), "->", e.NewFeeAddress.Hex())
+	return r.governance(l, "fee_address", e.OldFeeAddress.Hex(), e.NewFeeAddress.Hex(), "")
+}
+
+func (r *Registry) HandleSetMinAmount(l types.Log, e *SetMinAmount) error {
+	log.Println("SetMinAmount:", l.BlockNumber, e.OldMinAmount, "->", e.NewMinAmount)
+	return r.governance(l, "min_amount", e.OldMinAmount.String(), e.NewMinAmount.String(), "")
+}
 
-	log.Println("Deposit event:",
-		e.From.Hex(),
-		e.Token.Hex(),
-		e.Amount,
-		e.MintAmount,
-	)
+func (r *Registry) HandleOwnershipTransferred(l types.Log, e *OwnershipTransferred) error {
+	log.Println("OwnershipTransferred:", l.BlockNumber, e.PreviousOwner.Hex(), "->", e.NewOwner.Hex())
+	return r.governance(l, "owner", e.PreviousOwner.Hex(), e.NewOwner.Hex(), "")
 }
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PledgePool 事件结构体
+// 字段名必须和 ABI 参数名的驼峰形式一致（indexed 参数按名字从 topics 解析）
+
+// 出借人存款
+type DepositLend struct {
+	From       common.Address
+	Token      common.Address
+	Amount     *big.Int
+	MintAmount *big.Int
+}
+
+// 出借人领取未匹配部分的退款
+type RefundLend struct {
+	From   common.Address
+	Token  common.Address
+	Refund *big.Int
+}
+
+// 出借人领取 SP 凭证
+type ClaimLend struct {
+	From   common.Address
+	Token  common.Address
+	Amount *big.Int
+}
+
+// 出借人销毁 SP 赎回本息
+type WithdrawLend struct {
+	From       common.Address
+	Token      common.Address
+	Amount     *big.Int
+	BurnAmount *big.Int
+}
+
+// 借款人质押
+type DepositBorrow struct {
+	From       common.Address
+	Token      common.Address
+	Amount     *big.Int
+	MintAmount *big.Int
+}
+
+// 借款人领取未匹配部分的退款
+type RefundBorrow struct {
+	From   common.Address
+	Token  common.Address
+	Refund *big.Int
+}
+
+// 借款人领取 JP 凭证和借款
+type ClaimBorrow struct {
+	From   common.Address
+	Token  common.Address
+	Amount *big.Int
+}
+
+// 借款人销毁 JP 赎回剩余抵押物
+type WithdrawBorrow struct {
+	From       common.Address
+	Token      common.Address
+	Amount     *big.Int
+	BurnAmount *big.Int
+}
+
+// 结算/清算时通过路由兑换
+type Swap struct {
+	FromCoin  common.Address
+	ToCoin    common.Address
+	FromValue *big.Int
+	ToValue   *big.Int
+}
+
+// 池子未成交时出借人紧急提取
+type EmergencyLendWithdrawal struct {
+	From   common.Address
+	Token  common.Address
+	Amount *big.Int
+}
+
+// 池子未成交时借款人紧急提取
+type EmergencyBorrowWithdrawal struct {
+	From   common.Address
+	Token  common.Address
+	Amount *big.Int
+}
+
+// 池子状态变化（settle/finish/liquidate）
+type StateChange struct {
+	Pid         *big.Int
+	BeforeState *big.Int
+	AfterState  *big.Int
+}
+
+// 修改手续费
+type SetFee struct {
+	NewLendFee   *big.Int
+	NewBorrowFee *big.Int
+}
+
+// 修改 swap 路由
+type SetSwapRouterAddress struct {
+	OldSwapAddress common.Address
+	NewSwapAddress common.Address
+}
+
+// 修改手续费接收地址
+type SetFeeAddress struct {
+	OldFeeAddress common.Address
+	NewFeeAddress common.Address
+}
+
+// 修改最小存款金额
+type SetMinAmount struct {
+	OldMinAmount *big.Int
+	NewMinAmount *big.Int
+}
+
+// 手续费赎回（已部署合约仍会触发）
+type Redeem struct {
+	Recieptor common.Address
+	Token     common.Address
+	Amount    *big.Int
+}
+
+// 合约 owner 变更
+type OwnershipTransferred struct {
+	PreviousOwner common.Address
+	NewOwner      common.Address
+}
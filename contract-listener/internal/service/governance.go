@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"contract-listener/internal/model"
+	"contract-listener/internal/notify"
+	"contract-listener/internal/repo"
+	"log"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// 变更需要告警的参数；min_amount 只记录
+var criticalParams = map[string]bool{
+	"lend_fee":    true,
+	"borrow_fee":  true,
+	"swap_router": true,
+	"fee_address": true,
+	"paused":      true,
+	"owner":       true,
+}
+
+// 记录管理事件带来的参数变更，oldValue 为空时取上一次变更后的值，没有则用合约初始值 initial
+func (r *Registry) governance(l types.Log, param, oldValue, newValue, initial string) error {
+
+	if oldValue == "" {
+		prev, ok, err := repo.LastGovernanceValue(r.chainID, l.Address.Hex(), param, l.BlockNumber, l.Index)
+		if err != nil {
+			return err
+		}
+		oldValue = initial
+		if ok {
+			oldValue = prev
+		}
+	}
+
+	blockTime, err := r.blockTime(l)
+	if err != nil {
+		return err
+	}
+
+	tx, _, err := r.chain.TransactionByHash(context.Background(), l.TxHash)
+	if err != nil {
+		return err
+	}
+
+	sender, err := types.Sender(types.LatestSignerForChainID(new(big.Int).SetUint64(r.chainID)), tx)
+	if err != nil {
+		return err
+	}
+
+	return recordGovernance(model.GovernanceChange{
+		ChainID:   r.chainID,
+		Contract:  l.Address.Hex(),
+		Param:     param,
+		Old:       oldValue,
+		New:       newValue,
+		TxHash:    l.TxHash.Hex(),
+		LogIndex:  l.Index,
+		Block:     l.BlockNumber,
+		BlockTime: blockTime,
+		Sender:    sender.Hex(),
+	})
+}
+
+// 入库，关键参数变更通知 pledge-backend 发告警
+// 是否已告警以 governance_change.alerted_at 为准：pledge-backend 邮件发出后才填，定时补发为空的记录，
+// 这里的 PUBLISH 只是让它立即检查一次，发布失败、对方不在线都不会漏告警，重放、重试也不会重复告警
+func recordGovernance(g model.GovernanceChange) error {
+
+	g.Critical = criticalParams[g.Param]
+
+	log.Println("governance:", g.Param, g.Old, "->", g.New, "block", g.Block, "sender", g.Sender, "tx", g.TxHash)
+
+	if _, err := repo.SaveGovernanceChange(g); err != nil || !g.Critical {
+		return err
+	}
+
+	err := notify.PublishGovernance(notify.Governance{
+		ChainID:   strconv.FormatUint(g.ChainID, 10),
+		Contract:  g.Contract,
+		Param:     g.Param,
+		Old:       g.Old,
+		New:       g.New,
+		Sender:    g.Sender,
+		Block:     g.Block,
+		BlockTime: g.BlockTime.Unix(),
+		TxHash:    g.TxHash,
+	})
+	if err != nil {
+		log.Println("publish governance failed, left to backend sweep:", err)
+	}
+
+	return nil
+}
+
+// setPause 没有事件和参数，每次成功调用翻转 globalPaused（初始 false）
+func handleSetPause(c Call) error {
+
+	prev, ok, err := repo.LastGovernanceValue(c.ChainID, c.Contract.Hex(), "paused", c.Block, c.TxIndex)
+	if err != nil {
+		return err
+	}
+
+	paused := false
+	if ok {
+		paused, _ = strconv.ParseBool(prev)
+	}
+
+	return recordGovernance(model.GovernanceChange{
+		ChainID:   c.ChainID,
+		Contract:  c.Contract.Hex(),
+		Param:     "paused",
+		Old:       strconv.FormatBool(paused),
+		New:       strconv.FormatBool(!paused),
+		TxHash:    c.TxHash.Hex(),
+		LogIndex:  c.TxIndex,
+		Block:     c.Block,
+		BlockTime: c.BlockTime,
+		Sender:    c.From.Hex(),
+	})
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"contract-listener/internal/model"
+	"contract-listener/internal/repo"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// multiSignature 事件结构体
+
+// 发起申请：msgHash = keccak256(from, to)，to 为要调用的业务合约
+type CreateApplication struct {
+	From    common.Address
+	To      common.Address
+	MsgHash common.Hash
+}
+
+// 管理员签名
+type SignApplication struct {
+	From    common.Address
+	MsgHash common.Hash
+	Index   *big.Int
+}
+
+// 管理员撤回签名
+type RevokeApplication struct {
+	From    common.Address
+	MsgHash common.Hash
+	Index   *big.Int
+}
+
+// 替换管理员
+type TransferOwner struct {
+	Sender   common.Address
+	OldOwner common.Address
+	NewOwner common.Address
+}
+
+// 注册 multiSignature 全部事件
+func registerMultiSignature(r *Registry) {
+	register(r, "CreateApplication", r.HandleCreateApplication)
+	register(r, "SignApplication", r.HandleSignApplication)
+	register(r, "RevokeApplication", r.HandleRevokeApplication)
+	register(r, "TransferOwner", r.HandleTransferOwner)
+}
+
+func (r *Registry) HandleCreateApplication(l types.Log, e *CreateApplication) error {
+	log.Println("CreateApplication:", l.BlockNumber, e.From.Hex(), e.To.Hex(), e.MsgHash.Hex())
+
+	threshold, err := r.threshold(l.Address)
+	if err != nil {
+		return err
+	}
+
+	index, err := repo.SaveApplication(model.MultisigApplication{
+		ChainID:   r.chainID,
+		Contract:  l.Address.Hex(),
+		MsgHash:   e.MsgHash.Hex(),
+		Applicant: e.From.Hex(),
+		Target:    e.To.Hex(),
+		Threshold: threshold,
+		TxHash:    l.TxHash.Hex(),
+		LogIndex:  l.Index,
+		Block:     l.BlockNumber,
+	})
+	if err != nil {
+		return err
+	}
+
+	// 申请入库前已经到达的签名
+	return repo.RefreshApplication(r.chainID, l.Address.Hex(), e.MsgHash.Hex(), index)
+}
+
+func (r *Registry) HandleSignApplication(l types.Log, e *SignApplication) error {
+	log.Println("SignApplication:", l.BlockNumber, e.From.Hex(), e.MsgHash.Hex(), e.Index)
+	return r.saveSignature(l, e.From, e.MsgHash, e.Index, true)
+}
+
+func (r *Registry) HandleRevokeApplication(l types.Log, e *RevokeApplication) error {
+	log.Println("RevokeApplication:", l.BlockNumber, e.From.Hex(), e.MsgHash.Hex(), e.Index)
+	return r.saveSignature(l, e.From, e.MsgHash, e.Index, false)
+}
+
+// 管理员替换只入库；合约统计签名时不校验签名人是否仍是管理员，已有签名照常计数
+func (r *Registry) HandleTransferOwner(l types.Log, e *TransferOwner) error {
+	log.Println("TransferOwner:", l.BlockNumber, e.Sender.Hex(), e.OldOwner.Hex(), e.NewOwner.Hex())
+	return nil
+}
+
+func (r *Registry) saveSignature(l types.Log, signer common.Address, msgHash common.Hash, index *big.Int, signed bool) error {
+
+	err := repo.SaveSignature(model.MultisigSignature{
+		ChainID:  r.chainID,
+		Contract: l.Address.Hex(),
+		MsgHash:  msgHash.Hex(),
+		Index:    index.Uint64(),
+		Signer:   signer.Hex(),
+		Signed:   signed,
+		TxHash:   l.TxHash.Hex(),
+		LogIndex: l.Index,
+		Block:    l.BlockNumber,
+	})
+	if err != nil {
+		return err
+	}
+
+	return repo.RefreshApplication(r.chainID, l.Address.Hex(), msgHash.Hex(), index.Uint64())
+}
+
+// 签名阈值：构造时确定、之后不可修改，读一次后缓存
+func (r *Registry) threshold(addr common.Address) (uint64, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.multisigThreshold > 0 {
+		return r.multisigThreshold, nil
+	}
+
+	data, err := r.abi.Pack("threshold")
+	if err != nil {
+		return 0, err
+	}
+
+	out, err := r.chain.CallContract(context.Background(), ethereum.CallMsg{To: &addr, Data: data}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("call threshold failed: %w", err)
+	}
+
+	res, err := r.abi.Unpack("threshold", out)
+	if err != nil {
+		return 0, err
+	}
+
+	n, ok := res[0].(*big.Int)
+	if !ok {
+		return 0, fmt.Errorf("unexpected threshold type %T", res[0])
+	}
+
+	r.multisigThreshold = n.Uint64()
+	return r.multisigThreshold, nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"contract-listener/internal/model"
+	"contract-listener/internal/repo"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 把 BscPledgeOracle 喂价方法的 calldata 参数转成喂价记录，其他方法返回 nil
+// 只填写参数相关字段，链、交易、区块由调用方填写
+func OraclePrices(m *abi.Method, args []any) ([]model.OraclePrice, error) {
+
+	switch m.Name {
+
+	case "setPrice":
+		// priceMap[uint256(asset)] = price
+		asset, ok1 := args[0].(common.Address)
+		price, ok2 := args[1].(*big.Int)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%s: unexpected args %T %T", m.Name, args[0], args[1])
+		}
+		key := new(big.Int).SetBytes(asset.Bytes())
+		return []model.OraclePrice{{Method: m.Name, Underlying: key, Requested: key, Price: price}}, nil
+
+	case "setUnderlyingPrice":
+		// priceMap[underlying] = price
+		underlying, ok1 := args[0].(*big.Int)
+		price, ok2 := args[1].(*big.Int)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%s: unexpected args %T %T", m.Name, args[0], args[1])
+		}
+		return []model.OraclePrice{{Method: m.Name, Underlying: underlying, Requested: underlying, Price: price}}, nil
+
+	case "setPrices":
+		// 合约实现是 priceMap[i] = prices[i]，写入的 key 是下标而不是 assets[i]
+		// 按实际写入的 key 记录，参数里的资产另存一列便于审计
+		assets, ok1 := args[0].([]*big.Int)
+		prices, ok2 := args[1].([]*big.Int)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%s: unexpected args %T %T", m.Name, args[0], args[1])
+		}
+		if len(assets) != len(prices) {
+			return nil, nil // 合约里 require 失败，交易不会成功
+		}
+		list := make([]model.OraclePrice, len(prices))
+		for i := range prices {
+			list[i] = model.OraclePrice{
+				Seq:        i,
+				Method:     m.Name,
+				Underlying: big.NewInt(int64(i)),
+				Requested:  assets[i],
+				Price:      prices[i],
+			}
+		}
+		return list, nil
+	}
+
+	return nil, nil
+}
+
+// 喂价交易：逐条写入价格历史
+func handleOraclePrice(c Call) error {
+
+	prices, err := OraclePrices(c.Method, c.Args)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range prices {
+		p.ChainID = c.ChainID
+		p.Oracle = c.Contract.Hex()
+		p.TxHash = c.TxHash.Hex()
+		p.Block = c.Block
+		p.BlockTime = c.BlockTime
+
+		log.Println("oracle", p.Method, "block", p.Block, "underlying", p.Underlying, "price", p.Price)
+
+		if err := repo.SaveOraclePrice(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"contract-listener/internal/notify"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// calldata 里解析不出 pid（经其他合约间接调用），交给定时全量同步
+var errNoPid = errors.New("no _pid in calldata")
+
+// 通知 pledge-backend 刷新日志所属的池子，并删除 users 的持仓缓存
+// pid 为 nil 时从交易 calldata 里解析（用户操作类事件不带 pid）
+func (r *Registry) refreshPool(l types.Log, pid *big.Int, users ...common.Address) error {
+
+	if err := notify.InvalidatePositions(r.chainID, users...); err != nil {
+		return err
+	}
+
+	if !notify.Enabled() || l.Removed {
+		return nil
+	}
+
+	if pid == nil {
+		var err error
+		pid, err = r.poolID(l)
+		if errors.Is(err, errNoPid) {
+			log.Println("skip pool refresh:", err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	blockTime, err := r.blockTime(l)
+	if err != nil {
+		return err
+	}
+
+	return notify.PublishPoolRefresh(notify.PoolRefresh{
+		ChainID:   strconv.FormatUint(r.chainID, 10),
+		PoolID:    pid.Int64(),
+		Event:     r.EventName(l),
+		Block:     l.BlockNumber,
+		BlockHash: l.BlockHash.Hex(),
+		BlockTime: blockTime.Unix(),
+		TxHash:    l.TxHash.Hex(),
+	})
+}
+
+// 从交易 calldata 解析 pid：depositLend(_pid, ...) 等方法第一个参数都是 _pid
+func (r *Registry) poolID(l types.Log) (*big.Int, error) {
+
+	tx, _, err := r.chain.TransactionByHash(context.Background(), l.TxHash)
+	if err != nil {
+		return nil, err
+	}
+
+	data := tx.Data()
+	if len(data) < 4 {
+		return nil, fmt.Errorf("tx %s: %w", l.TxHash.Hex(), errNoPid)
+	}
+
+	// 通过其他合约间接调用时 calldata 不是本合约的方法
+	m, err := r.abi.MethodById(data[:4])
+	if err != nil {
+		return nil, fmt.Errorf("tx %s does not call pool contract directly: %w", l.TxHash.Hex(), errNoPid)
+	}
+
+	if len(m.Inputs) == 0 || m.Inputs[0].Name != "_pid" {
+		return nil, fmt.Errorf("tx %s method %s: %w", l.TxHash.Hex(), m.Name, errNoPid)
+	}
+
+	args, err := m.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	pid, ok := args[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("tx %s: unexpected _pid type %T", l.TxHash.Hex(), args[0])
+	}
+
+	return pid, nil
+}
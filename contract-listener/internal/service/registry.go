@@ -0,0 +1,372 @@
+package service
+
+import (
+	"context"
+	"contract-listener/internal/metrics"
+	"contract-listener/internal/model"
+	"contract-listener/internal/repo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var errUnknownTopic = errors.New("unknown event topic")
+
+// 链上读取：区块头（事件时间，带缓存）、交易（从 calldata 解析参数）、合约只读调用
+type ChainReader interface {
+	HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, block *big.Int) ([]byte, error)
+}
+
+// 处理模式
+type Mode int
+
+const (
+	ModeLive   Mode = iota // 入库去重，重复日志不再处理
+	ModeReplay             // 重放：入库后不管是否重复都调用处理函数，用于重建派生表
+	ModeDryRun             // 只解码打印，不入库也不调用处理函数
+)
+
+// 事件处理注册表：topic0 -> 解码 + 入库 + 处理
+type Registry struct {
+	abi      abi.ABI
+	chainID  uint64
+	contract string // 配置里的合约名，用作指标标签
+	chain    ChainReader
+	handlers map[common.Hash]func(l types.Log, force bool) error
+	names    map[common.Hash]string
+	mode     Mode
+
+	// 派生表的撤销：日志被重组掉时、检查点回退到分叉点时（可为空）
+	undo   map[common.Hash]func(l types.Log) error
+	rewind func(block uint64, addrs []common.Address) error
+
+	mu                sync.Mutex
+	multisigThreshold uint64 // MultiSignature 的签名阈值缓存
+}
+
+// 各合约的事件处理函数，key 为配置里的合约名
+var contracts = map[string]func(*Registry){
+	"PledgePool":     registerPledgePool,
+	"MultiSignature": registerMultiSignature,
+	"DebtToken":      registerDebtToken,
+}
+
+// 根据 ABI 构建注册表，并注册该合约的事件处理函数
+func NewRegistry(a abi.ABI, chainID uint64, contract string, chain ChainReader) *Registry {
+
+	r := &Registry{
+		abi:      a,
+		chainID:  chainID,
+		contract: contract,
+		chain:    chain,
+		handlers: make(map[common.Hash]func(l types.Log, force bool) error),
+		names:    make(map[common.Hash]string),
+		undo:     make(map[common.Hash]func(l types.Log) error),
+	}
+
+	for name, ev := range a.Events {
+		r.names[ev.ID] = name
+	}
+
+	if reg, ok := contracts[contract]; ok {
+		reg(r)
+	} else {
+		log.Println("contract has no handlers, events stored only:", contract)
+	}
+
+	// ABI 里有但没有处理函数的事件：只入库
+	for id, name := range r.names {
+		if _, ok := r.handlers[id]; !ok {
+			registerRaw(r, a.Events[name])
+		}
+	}
+
+	return r
+}
+
+// 切换处理模式，需在开始派发日志前调用
+func (r *Registry) SetMode(m Mode) {
+	r.mode = m
+}
+
+// 事件名对应的 topic0
+func (r *Registry) Topic(name string) (common.Hash, bool) {
+	ev, ok := r.abi.Events[name]
+	return ev.ID, ok
+}
+
+// 按事件名注册带类型的处理函数，处理函数返回错误时日志进入死信队列
+func register[T any](r *Registry, name string, h func(types.Log, *T) error) {
+
+	ev, ok := r.abi.Events[name]
+	if !ok {
+		log.Println("event not in abi:", name)
+		return
+	}
+
+	r.handlers[ev.ID] = func(l types.Log, force bool) error {
+
+		e := new(T)
+		if err := r.decode(ev, l, e); err != nil {
+			r.observe(ev.Name, err)
+			return err
+		}
+		r.observe(ev.Name, nil)
+
+		return r.apply(ev, l, force, func() error { return h(l, e) })
+	}
+}
+
+// 没有专门处理函数的事件：只入库
+func registerRaw(r *Registry, ev abi.Event) {
+	r.handlers[ev.ID] = func(l types.Log, force bool) error {
+
+		_, err := r.args(ev, l)
+		r.observe(ev.Name, err)
+		if err != nil {
+			return err
+		}
+
+		return r.apply(ev, l, force, func() error { return nil })
+	}
+}
+
+// 解码成功/失败计数
+func (r *Registry) observe(name string, err error) {
+
+	chainID := strconv.FormatUint(r.chainID, 10)
+
+	if err != nil {
+		metrics.DecodeFailures.WithLabelValues(chainID, r.contract, name).Inc()
+		return
+	}
+	metrics.EventsDecoded.WithLabelValues(chainID, r.contract, name).Inc()
+}
+
+// 入库并调用处理函数，force 表示已入库的日志也要调用处理函数
+func (r *Registry) apply(ev abi.Event, l types.Log, force bool, h func() error) error {
+
+	if r.mode == ModeDryRun {
+		args, _ := r.args(ev, l)
+		log.Printf("dry-run %s: block %d tx %s index %d %s\n", ev.Name, l.BlockNumber, l.TxHash.Hex(), l.Index, args)
+		return nil
+	}
+
+	// 被重组掉的日志走撤销流程，不再调用正常处理函数
+	if l.Removed {
+		if err := r.retract(ev.Name, l); err != nil {
+			return err
+		}
+		if u, ok := r.undo[ev.ID]; ok {
+			return u(l)
+		}
+		return nil
+	}
+
+	// 先入库，已经处理过的日志不再重复处理（重放模式除外）
+	inserted, err := r.save(ev, l)
+	if err != nil {
+		return err
+	}
+	if !inserted && !force {
+		log.Println("duplicate event skipped", ev.Name, l.TxHash.Hex(), l.Index)
+		return nil
+	}
+
+	if err := h(); err != nil {
+		return err
+	}
+
+	return r.enqueueWebhooks(ev, l)
+}
+
+// 检查点回退到分叉点：撤销 addrs 在 block 之后写入的派生数据
+func (r *Registry) Rewind(block uint64, addrs []common.Address) error {
+
+	if r.rewind == nil {
+		return nil
+	}
+
+	return r.rewind(block, addrs)
+}
+
+// 非 indexed 参数在 data，indexed 参数在 topics[1:]
+func (r *Registry) decode(ev abi.Event, l types.Log, out any) error {
+
+	if len(l.Data) > 0 {
+		if err := r.abi.UnpackIntoInterface(out, ev.Name, l.Data); err != nil {
+			return err
+		}
+	}
+
+	indexed := indexedArgs(ev)
+	if len(l.Topics)-1 != len(indexed) {
+		return fmt.Errorf("%s: expect %d indexed topics, got %d", ev.Name, len(indexed), len(l.Topics)-1)
+	}
+
+	return abi.ParseTopics(out, indexed, l.Topics[1:])
+}
+
+func indexedArgs(ev abi.Event) abi.Arguments {
+
+	var indexed abi.Arguments
+	for _, arg := range ev.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+
+	return indexed
+}
+
+// 参数转成 JSON：key 用 ABI 参数名，大整数转十进制字符串避免精度丢失
+func (r *Registry) args(ev abi.Event, l types.Log) (json.RawMessage, error) {
+
+	m := make(map[string]any)
+
+	if len(l.Data) > 0 {
+		if err := r.abi.UnpackIntoMap(m, ev.Name, l.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := abi.ParseTopicsIntoMap(m, indexedArgs(ev), l.Topics[1:]); err != nil {
+		return nil, err
+	}
+
+	for k, v := range m {
+		if b, ok := v.(*big.Int); ok {
+			m[k] = b.String()
+		}
+	}
+
+	return json.Marshal(m)
+}
+
+// 事件入库
+func (r *Registry) save(ev abi.Event, l types.Log) (bool, error) {
+
+	args, err := r.args(ev, l)
+	if err != nil {
+		return false, err
+	}
+
+	blockTime, err := r.blockTime(l)
+	if err != nil {
+		return false, err
+	}
+
+	return repo.SaveEvent(model.Event{
+		ChainID:   r.chainID,
+		TxHash:    l.TxHash.Hex(),
+		Block:     l.BlockNumber,
+		BlockHash: l.BlockHash.Hex(),
+		BlockTime: blockTime,
+		LogIndex:  l.Index,
+		Contract:  l.Address.Hex(),
+		Name:      ev.Name,
+		Args:      args,
+	})
+}
+
+// 日志所在区块的时间，区块头走缓存，同一区块的日志只请求一次
+// 停机排空队列时外层 ctx 已取消，这里不跟随，保证已派发的日志能处理完
+func (r *Registry) blockTime(l types.Log) (time.Time, error) {
+
+	h, err := r.chain.HeaderByHash(context.Background(), l.BlockHash)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(int64(h.Time), 0).UTC(), nil
+}
+
+// 撤销事件：已放行的日志所在区块被重组掉，推送过的 webhook 补一条撤回
+func (r *Registry) retract(name string, l types.Log) error {
+
+	log.Printf("retract %s: block %d tx %s index %d\n", name, l.BlockNumber, l.TxHash.Hex(), l.Index)
+
+	if err := repo.MarkEventRemoved(r.chainID, l.TxHash.Hex(), l.Index, l.BlockHash.Hex()); err != nil {
+		return err
+	}
+
+	return repo.SaveWebhookRetracts(r.chainID, l.TxHash.Hex(), l.Index, l.BlockHash.Hex())
+}
+
+// 分区键：用户操作类事件（第一个参数是 indexed from）按 合约+用户 分区，
+// 同一用户在同一合约上的存、取、领取严格按顺序处理；其他事件按合约分区
+// PledgePool 例外，全部按合约分区：StateChange 改变的是整个池子，用户事件又不带 pid
+// （要查交易 calldata，分发协程里不能逐条请求节点），按用户分区的话状态变化和存取、领取会在不同分区里乱序
+func (r *Registry) Key(l types.Log) string {
+
+	contract := l.Address.Hex()
+
+	if len(l.Topics) < 2 || r.contract == "PledgePool" {
+		return contract
+	}
+
+	name, ok := r.names[l.Topics[0]]
+	if !ok {
+		return contract
+	}
+
+	in := r.abi.Events[name].Inputs
+	if len(in) > 0 && in[0].Indexed && in[0].Name == "from" && in[0].Type.T == abi.AddressTy {
+		return contract + ":" + common.BytesToAddress(l.Topics[1].Bytes()).Hex()
+	}
+
+	return contract
+}
+
+// 分发一条日志，未知 topic 记录日志而不是静默丢弃
+func (r *Registry) Handle(l types.Log) error {
+	return r.handle(l, r.mode == ModeReplay)
+}
+
+// 死信重试：上次可能已入库但处理函数失败，所以不管是否重复都调用处理函数
+func (r *Registry) Retry(l types.Log) error {
+	return r.handle(l, true)
+}
+
+func (r *Registry) handle(l types.Log, force bool) error {
+
+	if len(l.Topics) == 0 {
+		log.Println("anonymous log skipped", l.TxHash.Hex(), l.Index)
+		return nil
+	}
+
+	h, ok := r.handlers[l.Topics[0]]
+	if !ok {
+		log.Println("unknown event topic", l.Topics[0].Hex(), "tx", l.TxHash.Hex(), "index", l.Index)
+		r.observe("unknown", errUnknownTopic)
+		return nil
+	}
+
+	if err := h(l, force); err != nil {
+		return fmt.Errorf("handle %s failed: %w", r.names[l.Topics[0]], err)
+	}
+
+	return nil
+}
+
+// 日志对应的事件名，未知 topic 返回空
+func (r *Registry) EventName(l types.Log) string {
+
+	if len(l.Topics) == 0 {
+		return ""
+	}
+
+	return r.names[l.Topics[0]]
+}
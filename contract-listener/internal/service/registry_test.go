@@ -0,0 +1,106 @@
+package service
+
+import (
+	"bytes"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func testRegistry(t *testing.T, contract string) *Registry {
+	t.Helper()
+
+	b, err := os.ReadFile("../../abi/" + contract + ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := abi.JSON(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewRegistry(a, 97, contract, nil)
+}
+
+// 事件名 + indexed 参数构造日志
+func testLog(t *testing.T, r *Registry, addr common.Address, name string, topics ...common.Hash) types.Log {
+	t.Helper()
+
+	id, ok := r.Topic(name)
+	if !ok {
+		t.Fatalf("event %s not in abi", name)
+	}
+
+	return types.Log{Address: addr, Topics: append([]common.Hash{id}, topics...)}
+}
+
+func TestRegistryKey(t *testing.T) {
+
+	var (
+		pool  = common.HexToAddress("0x216f718A983FCCb462b338FA9c60f2A89199490c")
+		token = common.HexToAddress("0x00000000000000000000000000000000000000aa")
+		alice = common.HexToAddress("0x00000000000000000000000000000000000000a1")
+		bob   = common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	)
+	pid := common.BigToHash(big.NewInt(3))
+	state := func(n int64) common.Hash { return common.BigToHash(big.NewInt(n)) }
+
+	pledge := testRegistry(t, "PledgePool")
+	debt := testRegistry(t, "DebtToken")
+
+	tests := []struct {
+		name string
+		a, b string // 两条日志的分区键
+		same bool
+	}{
+		{
+			// 池子状态变化和用户操作必须在同一分区按链上顺序处理
+			name: "pool state change and user deposit",
+			a:    pledge.Key(testLog(t, pledge, pool, "StateChange", pid, state(0), state(1))),
+			b:    pledge.Key(testLog(t, pledge, pool, "DepositLend", common.BytesToHash(alice.Bytes()), common.BytesToHash(token.Bytes()))),
+			same: true,
+		},
+		{
+			name: "pool state change and user claim",
+			a:    pledge.Key(testLog(t, pledge, pool, "StateChange", pid, state(1), state(2))),
+			b:    pledge.Key(testLog(t, pledge, pool, "ClaimBorrow", common.BytesToHash(bob.Bytes()), common.BytesToHash(token.Bytes()))),
+			same: true,
+		},
+		{
+			name: "two users on the pool contract",
+			a:    pledge.Key(testLog(t, pledge, pool, "DepositLend", common.BytesToHash(alice.Bytes()), common.BytesToHash(token.Bytes()))),
+			b:    pledge.Key(testLog(t, pledge, pool, "WithdrawLend", common.BytesToHash(bob.Bytes()), common.BytesToHash(token.Bytes()))),
+			same: true,
+		},
+		{
+			name: "same debt token holder",
+			a:    debt.Key(testLog(t, debt, token, "Transfer", common.BytesToHash(alice.Bytes()), common.BytesToHash(bob.Bytes()))),
+			b:    debt.Key(testLog(t, debt, token, "Transfer", common.BytesToHash(alice.Bytes()), common.Hash{})),
+			same: true,
+		},
+		{
+			name: "different debt token senders run in parallel",
+			a:    debt.Key(testLog(t, debt, token, "Transfer", common.BytesToHash(alice.Bytes()), common.BytesToHash(bob.Bytes()))),
+			b:    debt.Key(testLog(t, debt, token, "Transfer", common.BytesToHash(bob.Bytes()), common.BytesToHash(alice.Bytes()))),
+			same: false,
+		},
+		{
+			name: "same sender on different debt tokens",
+			a:    debt.Key(testLog(t, debt, token, "Transfer", common.BytesToHash(alice.Bytes()), common.BytesToHash(bob.Bytes()))),
+			b:    debt.Key(testLog(t, debt, pool, "Transfer", common.BytesToHash(alice.Bytes()), common.BytesToHash(bob.Bytes()))),
+			same: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.a == tt.b) != tt.same {
+				t.Errorf("keys %q and %q, want same=%v", tt.a, tt.b, tt.same)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"contract-listener/internal/model"
+	"contract-listener/internal/repo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// webhook 配置缓存时间，后台改动后最多这么久生效
+const webhookTTL = 30 * time.Second
+
+var webhookCache struct {
+	sync.Mutex
+	list   []model.Webhook
+	loaded time.Time
+}
+
+// 推送给 webhook 的事件；所在区块被重组掉时再推一次同样的内容，removed 为 true，按 id 对应原事件
+type WebhookPayload struct {
+	ID        string          `json:"id"` // <chain_id>:<tx_hash>:<log_index>
+	ChainID   string          `json:"chain_id"`
+	Contract  string          `json:"contract"`
+	Event     string          `json:"event"`
+	PoolID    *int64          `json:"pool_id"` // 解析不出时为 null
+	Block     uint64          `json:"block"`
+	BlockHash string          `json:"block_hash"`
+	BlockTime int64           `json:"block_time"` // 区块时间，unix 秒
+	TxHash    string          `json:"tx_hash"`
+	LogIndex  uint            `json:"log_index"`
+	Removed   bool            `json:"removed"`
+	Args      json.RawMessage `json:"args"` // key 为 ABI 参数名，大整数用十进制字符串
+}
+
+func webhooks() ([]model.Webhook, error) {
+
+	webhookCache.Lock()
+	defer webhookCache.Unlock()
+
+	if time.Since(webhookCache.loaded) < webhookTTL {
+		return webhookCache.list, nil
+	}
+
+	list, err := repo.GetWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	webhookCache.list = list
+	webhookCache.loaded = time.Now()
+
+	return list, nil
+}
+
+// 事件处理完成后，按过滤条件给匹配的 webhook 写入待投递记录，投递由 webhook 发送任务完成
+// 写入失败时日志进死信，重试时重复写入会被唯一键忽略
+func (r *Registry) enqueueWebhooks(ev abi.Event, l types.Log) error {
+
+	hooks, err := webhooks()
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+
+	blockTime, err := r.blockTime(l)
+	if err != nil {
+		return err
+	}
+
+	args, err := r.args(ev, l)
+	if err != nil {
+		return err
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(args, &m); err != nil {
+		return err
+	}
+
+	// 合约地址和参数里的所有地址都可用于匹配
+	addrs := []string{strings.ToLower(l.Address.Hex())}
+	for _, v := range m {
+		if s, ok := v.(string); ok && common.IsHexAddress(s) {
+			addrs = append(addrs, strings.ToLower(s))
+		}
+	}
+
+	var matched []model.Webhook
+	for _, w := range hooks {
+		if w.ChainID != 0 && w.ChainID != r.chainID {
+			continue
+		}
+		// 只推送创建之后的事件，重放历史区块不补推
+		if blockTime.Before(w.CreatedAt) {
+			continue
+		}
+		if len(w.Events) > 0 && !slices.Contains(w.Events, ev.Name) {
+			continue
+		}
+		if len(w.Addresses) > 0 && !slices.ContainsFunc(addrs, func(a string) bool { return slices.Contains(w.Addresses, a) }) {
+			continue
+		}
+		matched = append(matched, w)
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+
+	pid, err := r.eventPoolID(l, m)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(WebhookPayload{
+		ID:        fmt.Sprintf("%d:%s:%d", r.chainID, l.TxHash.Hex(), l.Index),
+		ChainID:   strconv.FormatUint(r.chainID, 10),
+		Contract:  l.Address.Hex(),
+		Event:     ev.Name,
+		PoolID:    pid,
+		Block:     l.BlockNumber,
+		BlockHash: l.BlockHash.Hex(),
+		BlockTime: blockTime.Unix(),
+		TxHash:    l.TxHash.Hex(),
+		LogIndex:  l.Index,
+		Args:      args,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, w := range matched {
+		if len(w.PoolIDs) > 0 && (pid == nil || !slices.Contains(w.PoolIDs, *pid)) {
+			continue
+		}
+
+		err := repo.SaveWebhookDelivery(model.WebhookDelivery{
+			WebhookID: w.ID,
+			ChainID:   r.chainID,
+			TxHash:    l.TxHash.Hex(),
+			LogIndex:  l.Index,
+			Event:     ev.Name,
+			Payload:   payload,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 事件所属池子：参数里带 pid 的直接用，DebtToken 查发现时记录的 pid，PledgePool 用户操作从 calldata 解析
+func (r *Registry) eventPoolID(l types.Log, args map[string]any) (*int64, error) {
+
+	if s, ok := args["pid"].(string); ok {
+		if pid, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return &pid, nil
+		}
+	}
+
+	switch r.contract {
+
+	case "DebtToken":
+		pid, ok, err := repo.GetDebtTokenPool(r.chainID, l.Address.Hex())
+		if err != nil || !ok {
+			return nil, err
+		}
+		return &pid, nil
+
+	case "PledgePool":
+		pid, err := r.poolID(l)
+		if errors.Is(err, errNoPid) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		v := pid.Int64()
+		return &v, nil
+	}
+
+	return nil, nil
+}
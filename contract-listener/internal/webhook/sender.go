@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"contract-listener/internal/model"
+	"contract-listener/internal/repo"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 每轮最多投递多少条
+const deliveryBatch = 50
+
+// 投递参数
+type Options struct {
+	Interval    time.Duration // 扫描到期投递的间隔
+	Timeout     time.Duration // 单次请求超时
+	BaseDelay   time.Duration // 第一次重试的等待时间，之后翻倍
+	MaxDelay    time.Duration // 重试等待时间上限
+	MaxAttempts int           // 一条投递最多尝试次数，超过置为 failed
+	MaxFailures int           // webhook 连续失败多少次后自动停用
+}
+
+// 第 attempts 次失败后的等待时间：BaseDelay * 2^(attempts-1)，不超过 MaxDelay
+func (o Options) backoff(attempts int) time.Duration {
+
+	d := o.BaseDelay
+	for i := 1; i < attempts && d < o.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > o.MaxDelay {
+		d = o.MaxDelay
+	}
+
+	return d
+}
+
+// 签名：hex(HMAC-SHA256(secret, "<timestamp>.<body>"))，接收方按同样方式计算后比对，并校验时间戳防重放
+func Sign(secret string, timestamp int64, body []byte) string {
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 把 webhook_delivery 里到期的记录 POST 给对方，非 2xx 按退避重试
+type Sender struct {
+	opts   Options
+	client *http.Client
+}
+
+func NewSender(o Options) *Sender {
+	return &Sender{
+		opts:   o,
+		client: &http.Client{Timeout: o.Timeout},
+	}
+}
+
+// 阻塞运行，ctx 取消后等本轮投递完成再返回
+func (s *Sender) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		list, err := repo.DueWebhookDeliveries(deliveryBatch)
+		if err != nil {
+			log.Println("webhook: load deliveries failed:", err)
+			continue
+		}
+
+		// 停机时不跟随 ctx，已发出的请求等它结束再记录结果
+		var wg sync.WaitGroup
+		for _, d := range list {
+			wg.Add(1)
+			go func(d model.WebhookDelivery) {
+				defer wg.Done()
+				s.deliver(d)
+			}(d)
+		}
+		wg.Wait()
+	}
+}
+
+func (s *Sender) deliver(d model.WebhookDelivery) {
+
+	code, err := s.post(d)
+	if err == nil {
+		if err := repo.MarkWebhookDelivered(d, code); err != nil {
+			log.Println("webhook: save delivery", d.ID, "failed:", err)
+		}
+		return
+	}
+
+	attempts := d.Attempts + 1
+	exhausted := attempts >= s.opts.MaxAttempts
+	next := time.Now().Add(s.opts.backoff(attempts))
+
+	log.Println("webhook", d.WebhookID, "delivery", d.ID, d.Event, d.TxHash, "attempt", attempts, "failed:", err)
+
+	disabled, err := repo.MarkWebhookFailed(d, code, err.Error(), next, exhausted, s.opts.MaxFailures)
+	if err != nil {
+		log.Println("webhook: save delivery", d.ID, "failed:", err)
+		return
+	}
+	if disabled {
+		log.Println("webhook", d.WebhookID, "disabled after", s.opts.MaxFailures, "consecutive failures")
+	}
+}
+
+// 返回对方的状态码，网络错误时为 0
+func (s *Sender) post(d model.WebhookDelivery) (int, error) {
+
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "pledge-webhook")
+	req.Header.Set("X-Pledge-Event", d.Event)
+	req.Header.Set("X-Pledge-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Pledge-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Pledge-Signature", "sha256="+Sign(d.Secret, ts, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode, nil
+	}
+
+	// 错误信息入库，去掉非法 UTF-8 和 NUL
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	msg := strings.ReplaceAll(strings.ToValidUTF8(string(body), ""), "\x00", "")
+
+	return resp.StatusCode, fmt.Errorf("http %d: %s", resp.StatusCode, msg)
+}
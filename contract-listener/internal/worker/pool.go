@@ -1,18 +1,91 @@
 package worker
 
-import "github.com/ethereum/go-ethereum/core/types"
+import (
+	"context"
+	"hash/fnv"
+	"sync"
 
-// worker池处理日志
-func Start(n int, jobs chan types.Log, handler func(types.Log)) {
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// 分区 worker 池
+// 同一个 key 的日志固定落到同一个分区串行处理，保证按 (block, logIndex) 顺序；
+// 不同 key 分散到不同分区并行处理
+type Pool struct {
+	parts   []chan types.Log
+	key     func(types.Log) string
+	handler func(types.Log)
+	wg      sync.WaitGroup
+}
+
+// n 个分区，每个分区队列长度 size
+// jobs 被关闭后分发协程把剩余日志分完再关闭各分区，分区处理完即退出
+func Start(n, size int, jobs chan types.Log, key func(types.Log) string, handler func(types.Log)) *Pool {
+
+	p := &Pool{
+		parts:   make([]chan types.Log, n),
+		key:     key,
+		handler: handler,
+	}
 
 	for i := 0; i < n; i++ {
 
-		go func() {
+		p.parts[i] = make(chan types.Log, size)
 
-			for j := range jobs {
-				handler(j)
+		p.wg.Add(1)
+		go func(ch chan types.Log) {
+			defer p.wg.Done()
+			for j := range ch {
+				p.handler(j)
 			}
+		}(p.parts[i])
+	}
+
+	// 单个分发协程：保持 jobs 里的到达顺序
+	go func() {
+		for j := range jobs {
+			p.parts[p.partition(j)] <- j
+		}
+		for _, ch := range p.parts {
+			close(ch)
+		}
+	}()
+
+	return p
+}
+
+func (p *Pool) partition(l types.Log) int {
 
-		}()
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(p.key(l)))
+
+	return int(h.Sum32() % uint32(len(p.parts)))
+}
+
+// 等待所有分区处理完（需先关闭 jobs），ctx 到期返回错误
+func (p *Pool) Wait(ctx context.Context) error {
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
+
+// 各分区当前排队数
+func (p *Pool) Depths() []int {
+
+	d := make([]int, len(p.parts))
+	for i, ch := range p.parts {
+		d[i] = len(ch)
+	}
+
+	return d
+}
@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// 日志的 TxIndex 借用来放 key，Index 为到达顺序
+func testKey(l types.Log) string {
+	return strconv.FormatUint(uint64(l.TxIndex), 10)
+}
+
+func TestPoolKeyOrder(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		workers int
+		keys    int
+		logs    int
+	}{
+		{name: "single partition", workers: 1, keys: 5, logs: 200},
+		{name: "more keys than partitions", workers: 4, keys: 20, logs: 2000},
+		{name: "more partitions than keys", workers: 8, keys: 3, logs: 600},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var mu sync.Mutex
+			got := make(map[string][]uint)
+
+			jobs := make(chan types.Log)
+			pool := Start(tt.workers, 4, jobs, testKey, func(l types.Log) {
+				// 处理耗时不一，乱序的话很容易暴露出来
+				time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+
+				mu.Lock()
+				got[testKey(l)] = append(got[testKey(l)], l.Index)
+				mu.Unlock()
+			})
+
+			want := make(map[string][]uint)
+			for i := 0; i < tt.logs; i++ {
+				l := types.Log{TxIndex: uint(rand.Intn(tt.keys)), Index: uint(i)}
+				want[testKey(l)] = append(want[testKey(l)], l.Index)
+				jobs <- l
+			}
+			close(jobs)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := pool.Wait(ctx); err != nil {
+				t.Fatal("wait:", err)
+			}
+
+			for k, w := range want {
+				g := got[k]
+				if len(g) != len(w) {
+					t.Fatalf("key %s: handled %d logs, want %d", k, len(g), len(w))
+				}
+				for i := range w {
+					if g[i] != w[i] {
+						t.Fatalf("key %s: position %d got log %d, want %d", k, i, g[i], w[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+// 一个 key 卡住时，其他分区的 key 照常处理
+func TestPoolKeysRunInParallel(t *testing.T) {
+
+	block := make(chan struct{})
+	done := make(chan string, 10)
+
+	jobs := make(chan types.Log, 10)
+	pool := Start(2, 4, jobs, testKey, func(l types.Log) {
+		if testKey(l) == "0" {
+			<-block
+		}
+		done <- testKey(l)
+	})
+
+	// 找一个和 key "0" 不在同一分区的 key
+	other := uint(1)
+	for pool.partition(types.Log{TxIndex: other}) == pool.partition(types.Log{TxIndex: 0}) {
+		other++
+	}
+
+	jobs <- types.Log{TxIndex: 0}
+	jobs <- types.Log{TxIndex: other}
+
+	select {
+	case k := <-done:
+		if k != testKey(types.Log{TxIndex: other}) {
+			t.Fatalf("got key %s first", k)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("other partition blocked by a stuck key")
+	}
+
+	if d := pool.Depths(); len(d) != 2 {
+		t.Fatalf("depths %v, want 2 partitions", d)
+	}
+
+	close(block)
+	close(jobs)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := pool.Wait(ctx); err != nil {
+		t.Fatal("wait:", err)
+	}
+}
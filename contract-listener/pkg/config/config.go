@@ -1,23 +1,155 @@
 package config
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
+// 一条链：节点、起始区块、确认深度和要监听的合约
+type Chain struct {
+	Name    string `mapstructure:"name"`
+	RPCWS   string `mapstructure:"rpc_ws"`
+	RPCHTTP string `mapstructure:"rpc_http"` // 可选，websocket 不可用时轮询
+
+	// 没有检查点时的起始区块（一般填合约部署区块），合约上可单独覆盖
+	StartBlock uint64 `mapstructure:"start_block"`
+
+	// 确认深度：区块被多少个后续区块覆盖后才视为最终，不填用全局 confirmations；可以填 0（本地链、测试链）
+	// Load 之后一定不为空
+	Confirmations *uint64 `mapstructure:"confirmations"`
+
+	Contracts []Contract `mapstructure:"contracts"`
+}
+
+// 一个合约：名称决定用哪组事件处理函数，检查点和 worker 按 (链, 合约) 隔离
+type Contract struct {
+	Name       string `mapstructure:"name"` // PledgePool / BscPledgeOracle / MultiSignature / DebtToken
+	Address    string `mapstructure:"address"`
+	ABI        string `mapstructure:"abi"`
+	StartBlock uint64 `mapstructure:"start_block"`
+
+	// 不填 address，从同一条链上该合约的池子信息里发现地址（DebtToken 填 PledgePool）
+	Discover string `mapstructure:"discover"`
+
+	// 额外按交易 calldata 索引不发事件的方法（BscPledgeOracle 喂价），逐块扫描，必须配好 start_block，否则不索引
+	Calldata bool `mapstructure:"calldata"`
+}
+
 type Config struct {
-	RPCWS     string `mapstructure:"rpc_ws"`
-	Contract  string `mapstructure:"contract"`
-	WorkerNum int    `mapstructure:"worker_num"`
+	Chains []Chain `mapstructure:"chains"`
+
+	// 旧版单链单合约配置，没有 chains 时使用
+	RPCWS    string `mapstructure:"rpc_ws"`
+	RPCHTTP  string `mapstructure:"rpc_http"`
+	Contract string `mapstructure:"contract"`
+
+	// 运维 HTTP 服务地址（/healthz /readyz /metrics），为空不启动
+	HTTPAddr string `mapstructure:"http_addr"`
+
+	WorkerNum int `mapstructure:"worker_num"`
+
+	// 每个 worker 分区的队列长度
+	WorkerQueue int `mapstructure:"worker_queue"`
+
+	StartBlock    uint64 `mapstructure:"start_block"`
+	Confirmations uint64 `mapstructure:"confirmations"`
+
+	// 每条链缓存的区块头个数（事件时间戳），补扫时按窗口批量预取
+	HeaderCacheSize int `mapstructure:"header_cache_size"`
+
+	// 停机时排空 worker 队列的最长等待时间
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+
+	Scan struct {
+		BatchSize uint64 `mapstructure:"batch_size"`
+		MinBatch  uint64 `mapstructure:"min_batch"`
+		MaxBatch  uint64 `mapstructure:"max_batch"`
+		MaxRetry  int    `mapstructure:"max_retry"`
+	} `mapstructure:"scan"`
+
+	// 死信队列：解码或处理失败的日志持久化后按退避自动重试
+	DeadLetter struct {
+		Interval    time.Duration `mapstructure:"interval"`     // 扫描到期死信的间隔
+		BaseDelay   time.Duration `mapstructure:"base_delay"`   // 第一次重试的等待时间，之后翻倍
+		MaxDelay    time.Duration `mapstructure:"max_delay"`    // 重试等待时间上限
+		MaxAttempts int           `mapstructure:"max_attempts"` // 超过后置为 failed，等人工 requeue
+	} `mapstructure:"dead_letter"`
+
+	// 解码后的事件按 链/合约地址 追加到 Redis Stream，下游用消费组读取；prefix 为空或没配 Redis 不启用
+	Stream struct {
+		Prefix   string        `mapstructure:"prefix"`
+		DB       int           `mapstructure:"db"`       // 和 redis.db 分开，pledge-backend 启动时会 FLUSHDB redis.db
+		MaxLen   int64         `mapstructure:"max_len"`  // 每个 Stream 近似保留的条数（XADD MAXLEN ~）
+		Interval time.Duration `mapstructure:"interval"` // 检查新事件的间隔
+	} `mapstructure:"stream"`
+
+	// 事件 webhook 推送：endpoint 和过滤条件在 pledge-backend 后台管理，这里负责投递
+	Webhook struct {
+		Enabled     bool          `mapstructure:"enabled"`
+		Interval    time.Duration `mapstructure:"interval"`     // 扫描到期投递的间隔
+		Timeout     time.Duration `mapstructure:"timeout"`      // 单次请求超时
+		BaseDelay   time.Duration `mapstructure:"base_delay"`   // 第一次重试的等待时间，之后翻倍
+		MaxDelay    time.Duration `mapstructure:"max_delay"`    // 重试等待时间上限
+		MaxAttempts int           `mapstructure:"max_attempts"` // 一条投递最多尝试次数
+		MaxFailures int           `mapstructure:"max_failures"` // 连续失败多少次自动停用 webhook
+	} `mapstructure:"webhook"`
 
 	DB struct {
 		DSN string `mapstructure:"dsn"`
 	} `mapstructure:"db"`
+
+	// 和 pledge-backend 共用的 Redis，addr 为空不发布通知
+	Redis struct {
+		Addr     string `mapstructure:"addr"`
+		Password string `mapstructure:"password"`
+		DB       int    `mapstructure:"db"`
+	} `mapstructure:"redis"`
+
+	// 发布给 pledge-backend 的频道和要失效的缓存
+	Notify struct {
+		PoolRefreshChannel string `mapstructure:"pool_refresh_channel"`
+		GovernanceChannel  string `mapstructure:"governance_channel"`
+		PositionsPrefix    string `mapstructure:"positions_prefix"`
+	} `mapstructure:"notify"`
 }
 
 func Load() Config {
 
 	viper.SetConfigFile("configs/config.yaml")
 
+	viper.SetDefault("worker_queue", 1000)
+	viper.SetDefault("confirmations", 15)
+	viper.SetDefault("shutdown_timeout", "30s")
+	viper.SetDefault("header_cache_size", 10000)
+
+	// 补扫窗口默认值（公共 BSC 节点一般限制 5000 块以内）
+	viper.SetDefault("scan.batch_size", 2000)
+	viper.SetDefault("scan.min_batch", 10)
+	viper.SetDefault("scan.max_batch", 5000)
+	viper.SetDefault("scan.max_retry", 5)
+
+	viper.SetDefault("dead_letter.interval", "30s")
+	viper.SetDefault("dead_letter.base_delay", "30s")
+	viper.SetDefault("dead_letter.max_delay", "1h")
+	viper.SetDefault("dead_letter.max_attempts", 10)
+
+	viper.SetDefault("webhook.interval", "5s")
+	viper.SetDefault("webhook.timeout", "10s")
+	viper.SetDefault("webhook.base_delay", "30s")
+	viper.SetDefault("webhook.max_delay", "1h")
+	viper.SetDefault("webhook.max_attempts", 8)
+	viper.SetDefault("webhook.max_failures", 20)
+
+	viper.SetDefault("stream.max_len", 100000)
+	viper.SetDefault("stream.interval", "3s")
+	viper.SetDefault("stream.db", 2)
+
+	viper.SetDefault("notify.pool_refresh_channel", "pledge:pool_refresh")
+	viper.SetDefault("notify.governance_channel", "pledge:governance")
+	viper.SetDefault("notify.positions_prefix", "pledge:positions")
+
 	if err := viper.ReadInConfig(); err != nil {
 		panic(err)
 	}
@@ -25,5 +157,39 @@ func Load() Config {
 	var c Config
 	viper.Unmarshal(&c)
 
+	// 兼容旧配置：单个 rpc_ws + contract 视为一条链上的 PledgePool
+	if len(c.Chains) == 0 && c.Contract != "" {
+		c.Chains = []Chain{{
+			Name:       "default",
+			RPCWS:      c.RPCWS,
+			RPCHTTP:    c.RPCHTTP,
+			StartBlock: c.StartBlock,
+			Contracts: []Contract{{
+				Name:    "PledgePool",
+				Address: c.Contract,
+				ABI:     "abi/PledgePool.json",
+			}},
+		}}
+	}
+
+	// Stream 和通知共用一个库的话，pledge-backend 重启 FLUSHDB 会清掉没消费的条目，而 streamed_at 已经记下
+	if c.Stream.Prefix != "" && c.Redis.Addr != "" && c.Stream.DB == c.Redis.DB {
+		panic(fmt.Sprintf("stream.db must differ from redis.db (%d), pledge-backend flushes that db on startup", c.Redis.DB))
+	}
+
+	// 没单独配置的按全局值
+	for i := range c.Chains {
+		ch := &c.Chains[i]
+		if ch.Confirmations == nil {
+			n := c.Confirmations
+			ch.Confirmations = &n
+		}
+		for j := range ch.Contracts {
+			if ch.Contracts[j].StartBlock == 0 {
+				ch.Contracts[j].StartBlock = ch.StartBlock
+			}
+		}
+	}
+
 	return c
 }
@@ -0,0 +1,187 @@
+package analytics
+
+import (
+	"math"
+
+	"github.com/shopspring/decimal"
+)
+
+// 池子派生指标：按 PledgePool 合约的算法，把 poolbases / pooldata / token_info.price 里 1e8 精度的原始值换算成小数
+// 比率类字段为小数（0.05 表示 5%），金额类字段为出借币最小单位的整数，都用十进制字符串
+
+var (
+	baseDecimal = decimal.New(1, 8)        // 合约里的 baseDecimal
+	baseYear    = decimal.New(31536000, 0) // 365 天，合约里的 baseYear
+)
+
+// 出参保留的小数位
+const places = 8
+
+// Pool 计算用到的池子参数，都是库里的原始字符串
+type Pool struct {
+	State                  string
+	SettleTime             string
+	EndTime                string
+	InterestRate           string
+	MartgageRate           string
+	AutoLiquidateThreshold string
+	MaxSupply              string
+	LendSupply             string
+	BorrowSupply           string
+	SettleAmountLend       string
+	SettleAmountBorrow     string
+	LendFee                string
+	BorrowFee              string
+	LendTokenPrice         string // 预言机价格，1e8 精度
+	BorrowTokenPrice       string
+}
+
+// Result 派生指标
+type Result struct {
+	InterestRate           string `json:"interest_rate"`            // 年化利率（单利）
+	MartgageRate           string `json:"martgage_rate"`            // 质押率：保证金价值 / 借款
+	AutoLiquidateThreshold string `json:"auto_liquidate_threshold"` // 清算阈值：保证金价值低于 借款 * (1 + 阈值) 时可清算
+	LendFee                string `json:"lend_fee"`
+	BorrowFee              string `json:"borrow_fee"`
+	TermDays               string `json:"term_days"`   // 结算到结束的天数
+	Utilization            string `json:"utilization"` // lend_supply / max_supply
+
+	// 按合约 finish 的资金流，整个期限的收益 / 成本折算成年化复利
+	// lendFee 不从出借方扣：finish 卖出 借款 * (1 + 利息) * (1 + lendFee) 的保证金，出借方拿到本息，多出的进 feeAddress
+	// borrowFee 按卖剩的保证金收（redeemFees），和借款金额无关
+	LendApy    string `json:"lend_apy"`    // 出借方年化收益，即利息本身
+	BorrowCost string `json:"borrow_cost"` // 借款方年化成本：利息 + lendFee * (1 + 利息) + borrowFee * 剩余保证金价值 / 借款
+
+	// 结算后按 settle_amount，结算前按 supply；保证金按预言机价格折算成出借币
+	CollateralValue string `json:"collateral_value"`
+	DebtValue       string `json:"debt_value"`
+	CollateralRatio string `json:"collateral_ratio"` // collateral_value / debt_value
+
+	// checkoutLiquidate：保证金价值 < 借款 * (1 + 清算阈值)，只有执行中（state 1）的池子能清算，其他状态为 false / "0"
+	Liquidatable         bool   `json:"liquidatable"`
+	LiquidationPriceDrop string `json:"liquidation_price_drop"` // 借款币相对出借币再跌多少触发清算，已触发为 0
+	LiquidationPrice     string `json:"liquidation_price"`      // 触发清算时借款币的预言机价格（1e8 精度，出借币价格不变）
+}
+
+// Compute 缺价格、还没有借贷的池子，对应字段为 "0"
+func Compute(p Pool) Result {
+
+	rate := ratio(p.InterestRate)
+	lendFee := ratio(p.LendFee)
+	borrowFee := ratio(p.BorrowFee)
+	threshold := ratio(p.AutoLiquidateThreshold)
+
+	res := Result{
+		InterestRate:           rate.StringFixed(places),
+		MartgageRate:           ratio(p.MartgageRate).StringFixed(places),
+		AutoLiquidateThreshold: threshold.StringFixed(places),
+		LendFee:                lendFee.StringFixed(places),
+		BorrowFee:              borrowFee.StringFixed(places),
+		TermDays:               "0",
+		Utilization:            div(num(p.LendSupply), num(p.MaxSupply)).StringFixed(places),
+		LendApy:                "0",
+		BorrowCost:             "0",
+		CollateralValue:        "0",
+		DebtValue:              "0",
+		CollateralRatio:        "0",
+		LiquidationPriceDrop:   "0",
+		LiquidationPrice:       "0",
+	}
+
+	// 期限（年），和合约 finish 里的 timeRatio 一样
+	one := decimal.NewFromInt(1)
+	years, interest := decimal.Zero, decimal.Zero
+	term := num(p.EndTime).Sub(num(p.SettleTime))
+	if term.IsPositive() {
+		years = term.Div(baseYear)
+		interest = rate.Mul(years)
+		res.TermDays = term.Div(decimal.New(86400, 0)).StringFixed(2)
+		res.LendApy = annualize(interest, years).StringFixed(places)
+	}
+	// 借款方按期限付出的：利息 + 卖保证金时多卖的 lendFee；borrowFee 部分要有价格才能折算，后面再加
+	cost := interest.Add(lendFee.Mul(one.Add(interest)))
+	setCost := func(c decimal.Decimal) {
+		if years.IsPositive() {
+			res.BorrowCost = annualize(c, years).StringFixed(places)
+		}
+	}
+	setCost(cost)
+
+	// 执行中、完成、清算用结算后的金额，其他状态（匹配中、未成立）用募集到的量
+	debt, collateral := num(p.LendSupply), num(p.BorrowSupply)
+	if p.State == "1" || p.State == "2" || p.State == "3" {
+		debt, collateral = num(p.SettleAmountLend), num(p.SettleAmountBorrow)
+	}
+	lendPrice, borrowPrice := num(p.LendTokenPrice), num(p.BorrowTokenPrice)
+	if !lendPrice.IsPositive() || !borrowPrice.IsPositive() {
+		return res
+	}
+
+	// 和 checkoutLiquidate 一样：保证金 * 借款币价格 / 出借币价格
+	collateralValue := collateral.Mul(borrowPrice).Div(lendPrice).Floor()
+	res.CollateralValue = collateralValue.String()
+	res.DebtValue = debt.String()
+	if !debt.IsPositive() || !collateralValue.IsPositive() {
+		return res
+	}
+	res.CollateralRatio = div(collateralValue, debt).StringFixed(places)
+
+	// finish 卖掉 借款 * (1 + 利息) * (1 + lendFee) 后剩下的保证金价值，borrowFee 按它收
+	remain := collateralValue.Sub(debt.Mul(one.Add(interest)).Mul(one.Add(lendFee)))
+	if remain.IsPositive() {
+		setCost(cost.Add(borrowFee.Mul(remain).Div(debt)))
+	}
+
+	if p.State != "1" {
+		return res
+	}
+
+	valueThreshold := debt.Mul(one.Add(threshold))
+	res.Liquidatable = collateralValue.LessThan(valueThreshold)
+	// 借款币价格 * (阈值价值 / 保证金价值) 时触发
+	trigger := div(valueThreshold, collateralValue)
+	res.LiquidationPrice = borrowPrice.Mul(trigger).StringFixed(0)
+	if !res.Liquidatable {
+		res.LiquidationPriceDrop = one.Sub(trigger).StringFixed(places)
+	}
+
+	return res
+}
+
+// 期限收益率 r 折算成年化复利：(1 + r)^(1/years) - 1；不足一天的期限不复利，按单利折算
+func annualize(r, years decimal.Decimal) decimal.Decimal {
+	if years.LessThan(decimal.New(1, 0).Div(decimal.New(365, 0))) {
+		return r.Div(years)
+	}
+	base, _ := decimal.NewFromInt(1).Add(r).Float64()
+	y, _ := years.Float64()
+	if base <= 0 {
+		return decimal.NewFromInt(-1)
+	}
+	v := math.Pow(base, 1/y) - 1
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return r.Div(years)
+	}
+	return decimal.NewFromFloat(v)
+}
+
+// 1e8 精度的比率
+func ratio(s string) decimal.Decimal {
+	return num(s).Div(baseDecimal)
+}
+
+func div(a, b decimal.Decimal) decimal.Decimal {
+	if b.IsZero() {
+		return decimal.Zero
+	}
+	return a.Div(b)
+}
+
+// 库里的十进制字符串，空或非法按 0
+func num(s string) decimal.Decimal {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
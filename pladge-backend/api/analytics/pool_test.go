@@ -0,0 +1,142 @@
+package analytics
+
+import "testing"
+
+// 期限一年，利率 10%、lendFee 1%、borrowFee 2%、清算阈值 20%，出借 1000、保证金 1000，借款币价格是出借币的 3 倍
+//
+//	finish：利息 100，lendAmount 1100，卖出 1100 * 1.01 = 1111 价值的保证金，出借方拿 1100，11 进 feeAddress
+//	        剩余保证金价值 3000 - 1111 = 1889，borrowFee 收 37.78
+//	出借方收益 100 / 1000 = 0.1
+//	借款方成本 (100 + 11 + 37.78) / 1000 = 0.14878
+//	checkoutLiquidate：阈值 1000 * 1.2 = 1200 < 3000，价格跌到 3e8 * 1200 / 3000 = 1.2e8 触发，还差 60%
+func testPool() Pool {
+	return Pool{
+		State:                  "1",
+		SettleTime:             "0",
+		EndTime:                "31536000",
+		InterestRate:           "10000000",
+		MartgageRate:           "200000000",
+		AutoLiquidateThreshold: "20000000",
+		MaxSupply:              "2000",
+		LendSupply:             "1000",
+		BorrowSupply:           "1000",
+		SettleAmountLend:       "1000",
+		SettleAmountBorrow:     "1000",
+		LendFee:                "1000000",
+		BorrowFee:              "2000000",
+		LendTokenPrice:         "100000000",
+		BorrowTokenPrice:       "300000000",
+	}
+}
+
+func TestCompute(t *testing.T) {
+
+	type want struct {
+		termDays, utilization, lendApy, borrowCost  string
+		collateralValue, debtValue, collateralRatio string
+		liquidatable                                bool
+		liquidationPriceDrop, liquidationPrice      string
+	}
+
+	tests := []struct {
+		name string
+		pool func(p *Pool)
+		want want
+	}{
+		{
+			name: "execution",
+			want: want{"365.00", "0.50000000", "0.10000000", "0.14878000", "3000", "1000", "3.00000000", false, "0.60000000", "120000000"},
+		},
+		{
+			// 价格缺失时 borrowFee 部分算不出来，只计利息和 lendFee：0.1 + 0.01 * 1.1
+			name: "zero price",
+			pool: func(p *Pool) { p.BorrowTokenPrice = "0" },
+			want: want{"365.00", "0.50000000", "0.10000000", "0.11100000", "0", "0", "0", false, "0", "0"},
+		},
+		{
+			name: "zero supply",
+			pool: func(p *Pool) {
+				p.State = "0"
+				p.LendSupply, p.BorrowSupply = "0", "0"
+				p.SettleAmountLend, p.SettleAmountBorrow = "0", "0"
+			},
+			want: want{"365.00", "0.00000000", "0.10000000", "0.11100000", "0", "0", "0", false, "0", "0"},
+		},
+		{
+			// 半天：不复利，按单利折算；利息 0.1 / 730，lendFee 0.01 * (1 + 0.1 / 730)，年化 (0.1 / 730 + 0.01 + 0.001 / 730) * 730
+			name: "term under one day",
+			pool: func(p *Pool) {
+				p.EndTime = "43200"
+				p.BorrowTokenPrice = "0"
+			},
+			want: want{"0.50", "0.50000000", "0.10000000", "7.40100000", "0", "0", "0", false, "0", "0"},
+		},
+		{
+			// 卖掉 1111 后保证金不够，没有剩余，不收 borrowFee
+			name: "nothing left after finish",
+			pool: func(p *Pool) { p.BorrowTokenPrice = "110000000" },
+			want: want{"365.00", "0.50000000", "0.10000000", "0.11100000", "1100", "1000", "1.10000000", true, "0", "120000000"},
+		},
+		{
+			// 保证金价值正好等于阈值，合约用严格小于，不能清算
+			name: "at liquidation threshold",
+			pool: func(p *Pool) {
+				p.SettleAmountBorrow = "1200"
+				p.BorrowTokenPrice = "100000000"
+			},
+			want: want{"365.00", "0.50000000", "0.10000000", "0.11278000", "1200", "1000", "1.20000000", false, "0.00000000", "100000000"},
+		},
+		{
+			// 1199 < 1200，可以清算；剩余 1199 - 1111 = 88，borrowFee 1.76
+			name: "below liquidation threshold",
+			pool: func(p *Pool) {
+				p.SettleAmountBorrow = "1199"
+				p.BorrowTokenPrice = "100000000"
+			},
+			want: want{"365.00", "0.50000000", "0.10000000", "0.11276000", "1199", "1000", "1.19900000", true, "0", "100083403"},
+		},
+		{
+			name: "finish",
+			pool: func(p *Pool) {
+				p.State = "2"
+				p.BorrowTokenPrice = "100000000"
+			},
+			want: want{"365.00", "0.50000000", "0.10000000", "0.11100000", "1000", "1000", "1.00000000", false, "0", "0"},
+		},
+		{
+			name: "liquidation",
+			pool: func(p *Pool) { p.State = "3" },
+			want: want{"365.00", "0.50000000", "0.10000000", "0.14878000", "3000", "1000", "3.00000000", false, "0", "0"},
+		},
+		{
+			// 未成立用募集到的量，已经不能清算
+			name: "undone",
+			pool: func(p *Pool) {
+				p.State = "4"
+				p.SettleAmountLend, p.SettleAmountBorrow = "0", "0"
+				p.BorrowSupply = "100"
+			},
+			want: want{"365.00", "0.50000000", "0.10000000", "0.11100000", "300", "1000", "0.30000000", false, "0", "0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testPool()
+			if tt.pool != nil {
+				tt.pool(&p)
+			}
+
+			r := Compute(p)
+
+			got := want{
+				r.TermDays, r.Utilization, r.LendApy, r.BorrowCost,
+				r.CollateralValue, r.DebtValue, r.CollateralRatio,
+				r.Liquidatable, r.LiquidationPriceDrop, r.LiquidationPrice,
+			}
+			if got != tt.want {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
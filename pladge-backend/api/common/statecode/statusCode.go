@@ -19,8 +19,25 @@ const (
 	ChainIdEmpty = 1202 //chain id empty
 	ChainIdErr   = 1203 //chain id error
 
+	MultiSignStatusErr = 1204 //multi-sign application status error
+
 	NameOrPasswordErr = 1303 //name or password error
 
+	// WebhookNotExist webhook
+	WebhookNotExist   = 1401 //webhook not exist
+	WebhookUrlErr     = 1402 //webhook url error
+	WebhookAddressErr = 1403 //webhook filter address error
+	WebhookPoolIdErr  = 1404 //webhook filter pool id error
+	WebhookStatusErr  = 1405 //webhook delivery status error
+
+	// AddressErr user
+	AddressErr = 1501 //wallet address error
+
+	// PoolNotExist pool
+	PoolNotExist   = 1601 //pool not exist
+	PoolSearchErr  = 1602 //pool search filter / sort / cursor error
+	PoolHistoryErr = 1603 //pool history interval / time range error
+
 )
 
 var Msg = map[int]map[int]string{
@@ -65,6 +82,11 @@ var Msg = map[int]map[int]string{
 		LangZhTw: "chain_id 錯誤",
 		LangEn:   "chain_id error",
 	},
+	1204: {
+		LangZh:   "status 错误",
+		LangZhTw: "status 錯誤",
+		LangEn:   "status error",
+	},
 	1301: {
 		LangZh:   "name 不能为空",
 		LangZhTw: "name 不能為空",
@@ -80,6 +102,51 @@ var Msg = map[int]map[int]string{
 		LangZhTw: "用戶名或密碼錯誤",
 		LangEn:   "name or password error",
 	},
+	1401: {
+		LangZh:   "webhook 不存在",
+		LangZhTw: "webhook 不存在",
+		LangEn:   "webhook not exist",
+	},
+	1402: {
+		LangZh:   "url 错误",
+		LangZhTw: "url 錯誤",
+		LangEn:   "url error",
+	},
+	1403: {
+		LangZh:   "addresses 错误",
+		LangZhTw: "addresses 錯誤",
+		LangEn:   "addresses error",
+	},
+	1404: {
+		LangZh:   "pool_ids 错误",
+		LangZhTw: "pool_ids 錯誤",
+		LangEn:   "pool_ids error",
+	},
+	1405: {
+		LangZh:   "status 错误",
+		LangZhTw: "status 錯誤",
+		LangEn:   "status error",
+	},
+	1501: {
+		LangZh:   "钱包地址错误",
+		LangZhTw: "錢包地址錯誤",
+		LangEn:   "address error",
+	},
+	1601: {
+		LangZh:   "池子不存在",
+		LangZhTw: "池子不存在",
+		LangEn:   "pool not exist",
+	},
+	1602: {
+		LangZh:   "搜索条件错误",
+		LangZhTw: "搜索條件錯誤",
+		LangEn:   "search parameter error",
+	},
+	1603: {
+		LangZh:   "时间粒度或时间范围错误",
+		LangZhTw: "時間粒度或時間範圍錯誤",
+		LangEn:   "interval or time range error",
+	},
 }
 
 func GetMsg(c int, lang int) string {
@@ -68,3 +68,29 @@ func (c *MultiSignPoolController) GetMultiSign(ctx *gin.Context) {
 	res.Response(ctx, statecode.CommonSuccess, result)
 	return
 }
+
+// GetApplications 查询链上多签申请：哪些管理操作待签、已通过、已撤回
+func (c *MultiSignPoolController) GetApplications(ctx *gin.Context) {
+	res := response.Gin{Res: ctx}
+	req := request.MultiSignApplications{}
+	log.Logger.Sugar().Info("GetApplications req ", nil)
+
+	// 1. 参数校验：ChainId、状态、分页
+	errCode := validate.NewMutiSign().GetApplications(ctx, &req)
+	if errCode != statecode.CommonSuccess {
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	// 2. 调用业务层：读取 contract-listener 索引的申请和签名
+	errCode, total, result, err := services.NewMutiSign().GetApplications(&req)
+	if errCode != statecode.CommonSuccess {
+		log.Logger.Error(err.Error())
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	// 3. 分页返回
+	res.ResponsePages(ctx, statecode.CommonSuccess, int(total), result)
+	return
+}
@@ -8,6 +8,7 @@ import (
 	"pledge-backend/api/services"
 	"pledge-backend/api/validate"
 	"pledge-backend/config"
+	"pledge-backend/log"
 	"regexp"
 	"strings"
 	"time"
@@ -63,6 +64,52 @@ func (c *PoolController) PoolDataInfo(ctx *gin.Context) {
 	return
 }
 
+// PoolDetail 单个池子的完整信息，不用再按下标拼接 poolBaseInfo 和 poolDataInfo
+func (c *PoolController) PoolDetail(ctx *gin.Context) {
+	res := response.Gin{Res: ctx}
+	req := request.PoolDetail{}
+	result := response.PoolDetail{}
+
+	errCode := validate.NewPoolDetail().PoolDetail(ctx, &req)
+	if errCode != statecode.CommonSuccess {
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	errCode, err := services.NewPool().PoolDetail(req.ChainId, req.PoolId, &result)
+	if errCode != statecode.CommonSuccess {
+		log.Logger.Error(err.Error())
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	res.Response(ctx, statecode.CommonSuccess, result)
+	return
+}
+
+// PoolHistory 池子的小时 / 日快照，用于画供应量、利用率、价格走势
+func (c *PoolController) PoolHistory(ctx *gin.Context) {
+	res := response.Gin{Res: ctx}
+	req := request.PoolHistory{}
+	result := response.PoolHistory{}
+
+	errCode := validate.NewPoolDetail().PoolHistory(ctx, &req)
+	if errCode != statecode.CommonSuccess {
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	errCode, err := services.NewPool().PoolHistory(&req, &result)
+	if errCode != statecode.CommonSuccess {
+		log.Logger.Error(err.Error())
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	res.Response(ctx, statecode.CommonSuccess, result)
+	return
+}
+
 // TokenList 获取协议支持的代币列表（遵循标准的 TokenList 格式）
 func (c *PoolController) TokenList(ctx *gin.Context) {
 
@@ -121,14 +168,15 @@ func (c *PoolController) Search(ctx *gin.Context) {
 		return
 	}
 	// 2. 执行搜索业务逻辑
-	errCode, count, pools := services.NewSearch().Search(&req)
+	errCode, count, pools, next := services.NewSearch().Search(&req)
 	if errCode != statecode.CommonSuccess {
 		res.Response(ctx, errCode, nil)
 		return
 	}
 
-	result.Rows = pools  // 搜索到的池子数据
-	result.Count = count // 总条数（用于分页）
+	result.Rows = pools      // 搜索到的池子数据
+	result.Count = count     // 符合条件的总条数
+	result.NextCursor = next // 下一页游标
 	res.Response(ctx, statecode.CommonSuccess, result)
 	return
 }
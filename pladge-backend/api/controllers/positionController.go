@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"pledge-backend/api/common/statecode"
+	"pledge-backend/api/models/request"
+	"pledge-backend/api/models/response"
+	"pledge-backend/api/services"
+	"pledge-backend/api/validate"
+	"pledge-backend/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PositionController 钱包维度的查询，前端不用再逐个池子读合约
+type PositionController struct {
+}
+
+// Positions 钱包在每个池子的质押、退款、SP/JP 余额，以及当前可领取 / 可退还 / 可赎回的金额
+func (c *PositionController) Positions(ctx *gin.Context) {
+	res := response.Gin{Res: ctx}
+	req := request.UserPositions{}
+	var result []response.UserPosition
+
+	errCode := validate.NewPosition().UserPositions(ctx, &req)
+	if errCode != statecode.CommonSuccess {
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	errCode, err := services.NewPosition().UserPositions(&req, &result)
+	if errCode != statecode.CommonSuccess {
+		log.Logger.Error(err.Error())
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	res.Response(ctx, statecode.CommonSuccess, result)
+	return
+}
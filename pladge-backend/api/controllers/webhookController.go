@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"pledge-backend/api/common/statecode"
+	"pledge-backend/api/models/request"
+	"pledge-backend/api/models/response"
+	"pledge-backend/api/services"
+	"pledge-backend/api/validate"
+	"pledge-backend/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookController 合作方事件推送：endpoint 管理和投递记录，投递由 contract-listener 完成
+type WebhookController struct {
+}
+
+// Create 新建 webhook，返回签名密钥（之后的列表里不再返回明文）
+func (c *WebhookController) Create(ctx *gin.Context) {
+	res := response.Gin{Res: ctx}
+	req := request.Webhook{}
+	result := response.Webhook{}
+
+	errCode := validate.NewWebhook().Save(ctx, &req)
+	if errCode != statecode.CommonSuccess {
+		res.Response(ctx, errCode, nil)
+		return
+	}
+	log.Logger.Sugar().Info("CreateWebhook req ", req.Url, req.ChainId, req.Events, req.PoolIds, req.Addresses)
+
+	errCode, err := services.NewWebhook().Create(&req, &result)
+	if errCode != statecode.CommonSuccess {
+		log.Logger.Error(err.Error())
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	res.Response(ctx, statecode.CommonSuccess, result)
+	return
+}
+
+// Update 修改过滤条件、启用/停用；自动停用的 webhook 修好后在这里重新启用
+func (c *WebhookController) Update(ctx *gin.Context) {
+	res := response.Gin{Res: ctx}
+	req := request.WebhookUpdate{}
+
+	errCode := validate.NewWebhook().Update(ctx, &req)
+	if errCode != statecode.CommonSuccess {
+		res.Response(ctx, errCode, nil)
+		return
+	}
+	log.Logger.Sugar().Info("UpdateWebhook req ", req.Id)
+
+	errCode, err := services.NewWebhook().Update(&req)
+	if errCode != statecode.CommonSuccess {
+		log.Logger.Error(err.Error())
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	res.Response(ctx, statecode.CommonSuccess, nil)
+	return
+}
+
+// Delete 删除 webhook 及其投递记录
+func (c *WebhookController) Delete(ctx *gin.Context) {
+	res := response.Gin{Res: ctx}
+	req := request.WebhookId{}
+
+	errCode := validate.NewWebhook().Id(ctx, &req)
+	if errCode != statecode.CommonSuccess {
+		res.Response(ctx, errCode, nil)
+		return
+	}
+	log.Logger.Sugar().Info("DeleteWebhook req ", req.Id)
+
+	errCode, err := services.NewWebhook().Delete(req.Id)
+	if errCode != statecode.CommonSuccess {
+		log.Logger.Error(err.Error())
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	res.Response(ctx, statecode.CommonSuccess, nil)
+	return
+}
+
+// List 全部 webhook 及其状态（连续失败次数、停用原因）
+func (c *WebhookController) List(ctx *gin.Context) {
+	res := response.Gin{Res: ctx}
+	var result []response.Webhook
+
+	errCode, err := services.NewWebhook().List(&result)
+	if errCode != statecode.CommonSuccess {
+		log.Logger.Error(err.Error())
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	res.Response(ctx, statecode.CommonSuccess, result)
+	return
+}
+
+// Deliveries 分页查询投递记录
+func (c *WebhookController) Deliveries(ctx *gin.Context) {
+	res := response.Gin{Res: ctx}
+	req := request.WebhookDeliveries{}
+
+	errCode := validate.NewWebhook().Deliveries(ctx, &req)
+	if errCode != statecode.CommonSuccess {
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	errCode, total, result, err := services.NewWebhook().Deliveries(&req)
+	if errCode != statecode.CommonSuccess {
+		log.Logger.Error(err.Error())
+		res.Response(ctx, errCode, nil)
+		return
+	}
+
+	res.ResponsePages(ctx, statecode.CommonSuccess, int(total), result)
+	return
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"pledge-backend/api/models/request"
+	"pledge-backend/db"
+	"time"
+)
+
+// MultiSignApplication contract-listener 索引的多签申请
+type MultiSignApplication struct {
+	Contract    string
+	MsgHash     string
+	Index       int
+	Applicant   string
+	Target      string
+	Threshold   int
+	Signatures  int
+	Status      string
+	TxHash      string
+	BlockNumber int64
+	CreatedAt   time.Time
+}
+
+// MultiSignSignature 管理员对申请的最新操作
+type MultiSignSignature struct {
+	Signer string
+	Signed bool
+}
+
+func NewMultiSignApplication() *MultiSignApplication {
+	return &MultiSignApplication{}
+}
+
+// Pagination 按状态分页查询申请，最新的在前
+func (m *MultiSignApplication) Pagination(req *request.MultiSignApplications) (error, int64, []MultiSignApplication) {
+
+	if db.Listener == nil {
+		return errors.New("listener db not configured"), 0, nil
+	}
+
+	var total int64
+	err := db.Listener.QueryRow(
+		`SELECT count(*) FROM multisig_application WHERE chain_id = $1 AND ($2 = '' OR status = $2)`,
+		req.ChainId, req.Status,
+	).Scan(&total)
+	if err != nil {
+		return errors.New("record select err " + err.Error()), 0, nil
+	}
+
+	rows, err := db.Listener.Query(
+		`SELECT contract, msg_hash, app_index, applicant, target, threshold, signatures, status, tx_hash, block_number, created_at
+		FROM multisig_application WHERE chain_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY block_number DESC, log_index DESC LIMIT $3 OFFSET $4`,
+		req.ChainId, req.Status, req.PageSize, (req.Page-1)*req.PageSize,
+	)
+	if err != nil {
+		return errors.New("record select err " + err.Error()), 0, nil
+	}
+	defer rows.Close()
+
+	var list []MultiSignApplication
+	for rows.Next() {
+		var a MultiSignApplication
+		err = rows.Scan(&a.Contract, &a.MsgHash, &a.Index, &a.Applicant, &a.Target, &a.Threshold,
+			&a.Signatures, &a.Status, &a.TxHash, &a.BlockNumber, &a.CreatedAt)
+		if err != nil {
+			return errors.New("record select err " + err.Error()), 0, nil
+		}
+		list = append(list, a)
+	}
+	if err = rows.Err(); err != nil {
+		return errors.New("record select err " + err.Error()), 0, nil
+	}
+	return nil, total, list
+}
+
+// SignList 申请的签名记录
+func (m *MultiSignApplication) SignList(chainId int) ([]MultiSignSignature, error) {
+
+	rows, err := db.Listener.Query(
+		`SELECT signer, signed FROM multisig_signature
+		WHERE chain_id = $1 AND contract = $2 AND msg_hash = $3 AND app_index = $4
+		ORDER BY block_number, log_index`,
+		chainId, m.Contract, m.MsgHash, m.Index,
+	)
+	if err != nil {
+		return nil, errors.New("record select err " + err.Error())
+	}
+	defer rows.Close()
+
+	var list []MultiSignSignature
+	for rows.Next() {
+		var s MultiSignSignature
+		if err = rows.Scan(&s.Signer, &s.Signed); err != nil {
+			return nil, errors.New("record select err " + err.Error())
+		}
+		list = append(list, s)
+	}
+	return list, rows.Err()
+}
@@ -2,11 +2,19 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
+	"pledge-backend/api/analytics"
 	"pledge-backend/api/models/request"
 	"pledge-backend/db"
 	"pledge-backend/schedule/models"
+	"pledge-backend/utils"
+	"strings"
+
+	"gorm.io/gorm"
 )
 
+var ErrPoolNotExist = errors.New("pool not exist")
+
 type Pool struct {
 	PoolID                 int      `json:"pool_id"`
 	SettleTime             string   `json:"settleTime"`
@@ -25,34 +33,128 @@ type Pool struct {
 	JpCoin                 string   `json:"jpCoin"`
 	AutoLiquidateThreshold string   `json:"autoLiquidateThreshold"`
 	Pooldata               PoolData `json:"pooldata"`
+
+	Analytics analytics.Result `json:"analytics"`
 }
 
 func NewPool() *Pool {
 	return &Pool{}
 }
 
-func (p *Pool) Pagination(req *request.Search, whereCondition string) (error, int64, []Pool) {
+// 可排序的字段：库里都是整数字符串，按数值比较
+var searchSortColumns = map[string]string{
+	"pool_id":       "pool_id",
+	"interest_rate": "CAST(interest_rate AS UNSIGNED)",
+	"martgage_rate": "CAST(martgage_rate AS UNSIGNED)",
+	"settle_time":   "CAST(settle_time AS UNSIGNED)",
+	"end_time":      "CAST(end_time AS UNSIGNED)",
+}
+
+// SearchSortable sortBy 是否可排序
+func SearchSortable(sortBy string) bool {
+	_, ok := searchSortColumns[sortBy]
+	return ok
+}
+
+// SearchCursor 游标：上一页最后一条的排序值和 pool_id
+type SearchCursor struct {
+	Value  int64 `json:"v"`
+	PoolID int   `json:"id"`
+}
+
+// Pagination 按筛选条件分页，有 cursor 时从游标之后取，否则按 page 偏移
+// req 由 service 补好默认值（SortBy、Order、PageSize），States 已合并旧的 State
+func (p *Pool) Pagination(req *request.Search, cursor *SearchCursor) (error, int64, []Pool, *SearchCursor) {
 	var total int64
 	pools := []Pool{}
 	poolBase := []models.PoolBase{}
 
-	db.Mysql.Table("poolbases").Where(whereCondition).Count(&total)
+	err := searchFilter(db.Mysql.Table("poolbases"), req).Count(&total).Error
+	if err != nil {
+		return errors.New("record select err " + err.Error()), 0, nil, nil
+	}
+
+	column := searchSortColumns[req.SortBy]
+	cmp := ">"
+	if req.Order == "desc" {
+		cmp = "<"
+	}
+
+	query := searchFilter(db.Mysql.Table("poolbases"), req)
+	if cursor != nil {
+		if req.SortBy == "pool_id" {
+			query = query.Where("pool_id "+cmp+" ?", cursor.PoolID)
+		} else {
+			query = query.Where("("+column+" "+cmp+" ? OR ("+column+" = ? AND pool_id "+cmp+" ?))",
+				cursor.Value, cursor.Value, cursor.PoolID)
+		}
+	} else if req.Page > 1 {
+		query = query.Offset((req.Page - 1) * req.PageSize)
+	}
+	if req.SortBy != "pool_id" {
+		query = query.Order(column + " " + req.Order)
+	}
 
-	err := db.Mysql.Table("poolbases").Where(whereCondition).Order("pool_id desc").Limit(req.PageSize).Offset((req.Page - 1) * req.PageSize).Find(&poolBase).Debug().Error
+	// 多取一条判断是否还有下一页
+	err = query.Order("pool_id " + req.Order).Limit(req.PageSize + 1).Find(&poolBase).Debug().Error
 	if err != nil {
-		return err, 0, nil
+		return errors.New("record select err " + err.Error()), 0, nil, nil
+	}
+
+	var next *SearchCursor
+	if len(poolBase) > req.PageSize {
+		poolBase = poolBase[:req.PageSize]
+		last := poolBase[len(poolBase)-1]
+		next = &SearchCursor{Value: searchSortValue(&last, req.SortBy), PoolID: last.PoolId}
+	}
+
+	// 每个池子对应自己的 pooldata
+	poolIds := make([]int, 0, len(poolBase))
+	for _, b := range poolBase {
+		poolIds = append(poolIds, b.PoolId)
+	}
+	var poolData []PoolData
+	if len(poolIds) > 0 {
+		err = db.Mysql.Table("pooldata").Where("chain_id = ? AND pool_id IN ?", req.ChainID, poolIds).Find(&poolData).Debug().Error
+		if err != nil {
+			return errors.New("record select err " + err.Error()), 0, nil, nil
+		}
+	}
+	dataById := make(map[int]PoolData, len(poolData))
+	for _, d := range poolData {
+		dataById[d.PoolID] = d
 	}
 
+	// 这一页用到的币的预言机价格
+	tokens := make([]string, 0, len(poolBase)*2)
 	for _, b := range poolBase {
-		poolData := PoolData{}
-		err = db.Mysql.Table("pooldata").Where("chain_id=?", req.ChainID).First(&poolData).Debug().Error
+		tokens = append(tokens, b.LendToken, b.BorrowToken)
+	}
+	var tokenInfo []models.TokenInfo
+	if len(tokens) > 0 {
+		err = db.Mysql.Table("token_info").Where("chain_id = ? AND token IN ?", req.ChainID, tokens).Find(&tokenInfo).Debug().Error
 		if err != nil {
-			return err, 0, nil
+			return errors.New("record select err " + err.Error()), 0, nil, nil
 		}
+	}
+	priceByToken := make(map[string]string, len(tokenInfo))
+	for _, t := range tokenInfo {
+		priceByToken[strings.ToLower(t.Token)] = t.Price
+	}
+
+	for _, b := range poolBase {
 		var lendToken models.LendToken
 		_ = json.Unmarshal([]byte(b.LendTokenInfo), &lendToken)
 		var borrowToken models.BorrowToken
 		_ = json.Unmarshal([]byte(b.BorrowTokenInfo), &borrowToken)
+		data := dataById[b.PoolId]
+		lendPrice, borrowPrice := priceByToken[strings.ToLower(b.LendToken)], priceByToken[strings.ToLower(b.BorrowToken)]
+		if lendPrice == "" {
+			lendPrice = lendToken.TokenPrice
+		}
+		if borrowPrice == "" {
+			borrowPrice = borrowToken.TokenPrice
+		}
 		pools = append(pools, Pool{
 			PoolID:                 b.PoolId,
 			SettleTime:             b.SettleTime,
@@ -63,13 +165,124 @@ func (p *Pool) Pagination(req *request.Search, whereCondition string) (error, in
 			BorrowSupply:           b.BorrowSupply,
 			MartgageRate:           b.MartgageRate,
 			LendToken:              lendToken.TokenName,
+			LendTokenSymbol:        b.LendTokenSymbol,
 			BorrowToken:            borrowToken.TokenName,
+			BorrowTokenSymbol:      b.BorrowTokenSymbol,
 			State:                  b.State,
 			SpCoin:                 b.SpCoin,
 			JpCoin:                 b.JpCoin,
 			AutoLiquidateThreshold: b.AutoLiquidateThreshold,
-			Pooldata:               poolData,
+			Pooldata:               data,
+			Analytics: analytics.Compute(analytics.Pool{
+				State:                  b.State,
+				SettleTime:             b.SettleTime,
+				EndTime:                b.EndTime,
+				InterestRate:           b.InterestRate,
+				MartgageRate:           b.MartgageRate,
+				AutoLiquidateThreshold: b.AutoLiquidateThreshold,
+				MaxSupply:              b.MaxSupply,
+				LendSupply:             b.LendSupply,
+				BorrowSupply:           b.BorrowSupply,
+				SettleAmountLend:       data.SettleAmountLend,
+				SettleAmountBorrow:     data.SettleAmountBorrow,
+				LendFee:                lendToken.LendFee,
+				BorrowFee:              borrowToken.BorrowFee,
+				LendTokenPrice:         lendPrice,
+				BorrowTokenPrice:       borrowPrice,
+			}),
 		})
 	}
-	return nil, total, pools
+	return nil, total, pools, next
+}
+
+// 筛选条件，全部走占位符
+func searchFilter(query *gorm.DB, req *request.Search) *gorm.DB {
+	query = query.Where("chain_id = ?", req.ChainID)
+	if req.LendTokenSymbol != "" {
+		query = query.Where("lend_token_symbol = ?", req.LendTokenSymbol)
+	}
+	if req.BorrowTokenSymbol != "" {
+		query = query.Where("borrow_token_symbol = ?", req.BorrowTokenSymbol)
+	}
+	if len(req.States) > 0 {
+		query = query.Where("state IN ?", req.States)
+	}
+	ranges := []struct {
+		column   string
+		min, max int64
+	}{
+		{"interest_rate", req.InterestRateMin, req.InterestRateMax},
+		{"martgage_rate", req.MartgageRateMin, req.MartgageRateMax},
+		{"settle_time", req.SettleTimeFrom, req.SettleTimeTo},
+		{"end_time", req.EndTimeFrom, req.EndTimeTo},
+	}
+	for _, r := range ranges {
+		if r.min > 0 {
+			query = query.Where(searchSortColumns[r.column]+" >= ?", r.min)
+		}
+		if r.max > 0 {
+			query = query.Where(searchSortColumns[r.column]+" <= ?", r.max)
+		}
+	}
+	return query
+}
+
+func searchSortValue(b *models.PoolBase, sortBy string) int64 {
+	switch sortBy {
+	case "interest_rate":
+		return utils.StringToInt64(b.InterestRate)
+	case "martgage_rate":
+		return utils.StringToInt64(b.MartgageRate)
+	case "settle_time":
+		return utils.StringToInt64(b.SettleTime)
+	case "end_time":
+		return utils.StringToInt64(b.EndTime)
+	}
+	return int64(b.PoolId)
+}
+
+// PoolDetail 一个池子的 poolbases、pooldata 和借贷币 token_info
+type PoolDetail struct {
+	Base        models.PoolBase
+	Data        *models.PoolData // 还没同步过 pooldata 时为 nil
+	LendToken   models.TokenInfo // token_info 里没有时为空
+	BorrowToken models.TokenInfo
+}
+
+// Detail poolId 为库里的 pool_id（合约 pid+1）
+func (p *Pool) Detail(chainId, poolId int) (error, *PoolDetail) {
+	detail := PoolDetail{}
+
+	err := db.Mysql.Table("poolbases").Where("chain_id=? and pool_id=?", chainId, poolId).First(&detail.Base).Debug().Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrPoolNotExist, nil
+	}
+	if err != nil {
+		return errors.New("record select err " + err.Error()), nil
+	}
+
+	var poolData []models.PoolData
+	err = db.Mysql.Table("pooldata").Where("chain_id=? and pool_id=?", chainId, poolId).Limit(1).Find(&poolData).Debug().Error
+	if err != nil {
+		return errors.New("record select err " + err.Error()), nil
+	}
+	if len(poolData) > 0 {
+		detail.Data = &poolData[0]
+	}
+
+	var tokens []models.TokenInfo
+	err = db.Mysql.Table("token_info").Where("chain_id=? and token in ?", chainId, []string{detail.Base.LendToken, detail.Base.BorrowToken}).Find(&tokens).Debug().Error
+	if err != nil {
+		return errors.New("record select err " + err.Error()), nil
+	}
+	for _, t := range tokens {
+		if strings.EqualFold(t.Token, detail.Base.LendToken) {
+			detail.LendToken = t
+		}
+		if strings.EqualFold(t.Token, detail.Base.BorrowToken) {
+			detail.BorrowToken = t
+		}
+	}
+
+	return nil, &detail
 }
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+	"pledge-backend/db"
+)
+
+// PositionPool 计算用户持仓用到的池子参数：poolbases 左连 pooldata（还没结算的池子数据都是 0）
+type PositionPool struct {
+	PoolId                 int
+	State                  string
+	SettleTime             string
+	EndTime                string
+	LendSupply             string
+	BorrowSupply           string
+	MartgageRate           string
+	LendToken              string
+	BorrowToken            string
+	SpCoin                 string
+	JpCoin                 string
+	SettleAmountLend       string
+	SettleAmountBorrow     string
+	FinishAmountLend       string
+	FinishAmountBorrow     string
+	LiquidationAmounLend   string
+	LiquidationAmounBorrow string
+}
+
+func NewPositionPool() *PositionPool {
+	return &PositionPool{}
+}
+
+// List 链上所有池子，按 pool_id 升序
+func (p *PositionPool) List(chainId int) ([]PositionPool, error) {
+	var pools []PositionPool
+
+	err := db.Mysql.Table("poolbases b").
+		Select(`b.pool_id, b.state, b.settle_time, b.end_time, b.lend_supply, b.borrow_supply, b.martgage_rate,
+			b.lend_token, b.borrow_token, b.sp_coin, b.jp_coin,
+			IFNULL(d.settle_amount_lend, '0') AS settle_amount_lend,
+			IFNULL(d.settle_amount_borrow, '0') AS settle_amount_borrow,
+			IFNULL(d.finish_amount_lend, '0') AS finish_amount_lend,
+			IFNULL(d.finish_amount_borrow, '0') AS finish_amount_borrow,
+			IFNULL(d.liquidation_amoun_lend, '0') AS liquidation_amoun_lend,
+			IFNULL(d.liquidation_amoun_borrow, '0') AS liquidation_amoun_borrow`).
+		Joins("LEFT JOIN pooldata d ON d.chain_id = b.chain_id AND d.pool_id = b.pool_id").
+		Where("b.chain_id = ?", chainId).
+		Order("b.pool_id asc").
+		Scan(&pools).Error
+	if err != nil {
+		return nil, errors.New("record select err " + err.Error())
+	}
+	return pools, nil
+}
@@ -16,3 +16,10 @@ type SetMultiSign struct {
 type GetMultiSign struct {
 	ChainId int `json:"chain_id"`
 }
+
+type MultiSignApplications struct {
+	ChainId  int    `json:"chain_id"`
+	Status   string `json:"status"` // pending / approved / revoked，为空返回全部
+	Page     int    `json:"page"`
+	PageSize int    `json:"pageSize"`
+}
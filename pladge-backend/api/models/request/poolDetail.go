@@ -0,0 +1,15 @@
+package request
+
+// 单个池子详情，参数取自路径 /pool/:chainId/:poolId
+type PoolDetail struct {
+	ChainId int `uri:"chainId" binding:"required"`
+	PoolId  int `uri:"poolId" binding:"required"` // 库里的 pool_id，合约 pid+1
+}
+
+// 池子历史快照，interval 为 hour / day，from、to 为 unix 秒
+type PoolHistory struct {
+	PoolDetail
+	Interval string `form:"interval"`
+	From     int64  `form:"from"`
+	To       int64  `form:"to"`
+}
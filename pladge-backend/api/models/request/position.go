@@ -0,0 +1,7 @@
+package request
+
+// 钱包在各池子的持仓，address 取自路径 /user/:address/positions
+type UserPositions struct {
+	Address string `form:"-"`
+	ChainId int    `form:"chainId" binding:"required"`
+}
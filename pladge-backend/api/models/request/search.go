@@ -1,9 +1,23 @@
 package request
 
+// Search 池子搜索，筛选条件为空（0）表示不限；利率、质押率为合约里的 1e8 精度，时间为 unix 秒
 type Search struct {
-	ChainID         int    `form:"chainID" json:"chainID" binding:"required"`
-	LendTokenSymbol string `form:"lend_token_symbol" json:"lend_token_symbol" binding:"omitempty"`
-	State           string `form:"state" json:"state" binding:"omitempty"`
-	Page            int    `form:"page" json:"page" `
-	PageSize        int    `form:"pageSize" json:"pageSize" `
+	ChainID           int      `form:"chainID" json:"chainID" binding:"required"`
+	LendTokenSymbol   string   `form:"lend_token_symbol" json:"lend_token_symbol" binding:"omitempty"`
+	BorrowTokenSymbol string   `form:"borrow_token_symbol" json:"borrow_token_symbol" binding:"omitempty"`
+	State             string   `form:"state" json:"state" binding:"omitempty"` // 单个状态，兼容旧参数，和 states 合并
+	States            []string `form:"states" json:"states"`                   // 0 match / 1 execution / 2 finish / 3 liquidation / 4 undone
+	InterestRateMin   int64    `form:"interest_rate_min" json:"interest_rate_min"`
+	InterestRateMax   int64    `form:"interest_rate_max" json:"interest_rate_max"`
+	MartgageRateMin   int64    `form:"martgage_rate_min" json:"martgage_rate_min"`
+	MartgageRateMax   int64    `form:"martgage_rate_max" json:"martgage_rate_max"`
+	SettleTimeFrom    int64    `form:"settle_time_from" json:"settle_time_from"`
+	SettleTimeTo      int64    `form:"settle_time_to" json:"settle_time_to"`
+	EndTimeFrom       int64    `form:"end_time_from" json:"end_time_from"`
+	EndTimeTo         int64    `form:"end_time_to" json:"end_time_to"`
+	SortBy            string   `form:"sort_by" json:"sort_by"` // pool_id（默认）/ interest_rate / martgage_rate / settle_time / end_time
+	Order             string   `form:"order" json:"order"`     // desc（默认）/ asc
+	Cursor            string   `form:"cursor" json:"cursor"`   // 上一页返回的 next_cursor，为空从第一条开始
+	Page              int      `form:"page" json:"page" `      // 没有 cursor 时按页码偏移，兼容旧调用
+	PageSize          int      `form:"pageSize" json:"pageSize" `
 }
@@ -0,0 +1,34 @@
+package request
+
+// 新建 webhook，过滤条件为空表示不限
+type Webhook struct {
+	Url       string   `json:"url"`      // http(s) 地址
+	Secret    string   `json:"secret"`   // 签名密钥，为空自动生成
+	ChainId   int      `json:"chain_id"` // 0 表示所有链
+	Events    []string `json:"events"`   // 事件名，如 DepositLend、ClaimBorrow、StateChange
+	PoolIds   []int64  `json:"pool_ids"` // 合约里的 pid，从 0 开始
+	Addresses []string `json:"addresses"`
+}
+
+// 修改 webhook，只改传了的字段（不传为 nil）；过滤条件传空数组表示不限
+type WebhookUpdate struct {
+	Id        int64     `json:"id"`
+	Url       *string   `json:"url"`
+	Secret    *string   `json:"secret"` // 传空字符串也保持不变
+	ChainId   *int      `json:"chain_id"`
+	Events    *[]string `json:"events"`
+	PoolIds   *[]int64  `json:"pool_ids"`
+	Addresses *[]string `json:"addresses"`
+	Enabled   *bool     `json:"enabled"` // true 会清零连续失败次数
+}
+
+type WebhookId struct {
+	Id int64 `json:"id"`
+}
+
+type WebhookDeliveries struct {
+	WebhookId int64  `json:"webhook_id"` // 0 表示全部
+	Status    string `json:"status"`     // pending / delivered / failed，为空返回全部
+	Page      int    `json:"page"`
+	PageSize  int    `json:"pageSize"`
+}
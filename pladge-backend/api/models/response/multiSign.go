@@ -12,3 +12,19 @@ type MultiSign struct {
 	JpHash           string   `json:"jpHash"`
 	MultiSignAccount []string `json:"multi_sign_account"`
 }
+
+// multi-sign application indexed by contract-listener
+type MultiSignApplication struct {
+	MsgHash     string   `json:"msg_hash"`
+	Index       int      `json:"index"`
+	Applicant   string   `json:"applicant"`
+	Target      string   `json:"target"` // 业务合约地址
+	Threshold   int      `json:"threshold"`
+	Signatures  int      `json:"signatures"` // 当前有效签名数
+	Status      string   `json:"status"`     // pending / approved / revoked
+	Signers     []string `json:"signers"`    // 当前有效签名的管理员
+	Revoked     []string `json:"revoked"`    // 签过名又撤回的管理员
+	TxHash      string   `json:"tx_hash"`
+	BlockNumber int64    `json:"block_number"`
+	CreatedAt   int64    `json:"created_at"`
+}
@@ -0,0 +1,82 @@
+package response
+
+import "pledge-backend/api/analytics"
+
+// 单个池子详情：poolbases + pooldata + 借贷币信息 + 派生字段
+type PoolDetail struct {
+	PoolId                 int    `json:"pool_id"`
+	ChainId                int    `json:"chain_id"`
+	State                  string `json:"state"`      // match / execution / finish / liquidation / undone
+	StateCode              int    `json:"state_code"` // 合约里的枚举值
+	SettleTime             int64  `json:"settle_time"`
+	EndTime                int64  `json:"end_time"`
+	SettleCountdown        int64  `json:"settle_countdown"` // 距结算时间的秒数，已过为 0
+	EndCountdown           int64  `json:"end_countdown"`    // 距结束时间的秒数，已过为 0
+	InterestRate           string `json:"interest_rate"`
+	MaxSupply              string `json:"max_supply"`
+	LendSupply             string `json:"lend_supply"`
+	BorrowSupply           string `json:"borrow_supply"`
+	MartgageRate           string `json:"martgage_rate"`
+	AutoLiquidateThreshold string `json:"auto_liquidate_threshold"`
+	SpCoin                 string `json:"sp_coin"`
+	JpCoin                 string `json:"jp_coin"`
+
+	LendToken   PoolToken      `json:"lend_token"`
+	BorrowToken PoolToken      `json:"borrow_token"`
+	Fees        PoolFees       `json:"fees"`
+	Data        PoolDetailData `json:"data"`
+
+	Analytics analytics.Result `json:"analytics"` // 利用率、收益、抵押覆盖和清算距离
+}
+
+// 借 / 贷币信息，价格为预言机价格（1e8 精度）
+type PoolToken struct {
+	Address        string `json:"address"`
+	Symbol         string `json:"symbol"`
+	Logo           string `json:"logo"`
+	Decimals       int    `json:"decimals"`
+	Price          string `json:"price"`
+	PriceUpdatedAt string `json:"price_updated_at"`
+}
+
+// 协议手续费（1e8 精度），池子同步时从合约读取
+type PoolFees struct {
+	LendFee   string `json:"lend_fee"`
+	BorrowFee string `json:"borrow_fee"`
+}
+
+// pooldata：结算、完成、清算时的金额，还没发生的为 0
+type PoolDetailData struct {
+	SettleAmountLend        string `json:"settle_amount_lend"`
+	SettleAmountBorrow      string `json:"settle_amount_borrow"`
+	FinishAmountLend        string `json:"finish_amount_lend"`
+	FinishAmountBorrow      string `json:"finish_amount_borrow"`
+	LiquidationAmountLend   string `json:"liquidation_amount_lend"`
+	LiquidationAmountBorrow string `json:"liquidation_amount_borrow"`
+	UpdatedAt               string `json:"updated_at"`
+}
+
+// 池子历史走势，每个点是一个时间桶里最后一次采样的值
+type PoolHistory struct {
+	PoolId   int                `json:"pool_id"`
+	ChainId  int                `json:"chain_id"`
+	Interval string             `json:"interval"`
+	Points   []PoolHistoryPoint `json:"points"`
+}
+
+type PoolHistoryPoint struct {
+	Time                    int64   `json:"time"` // 桶起始时间（UTC 整点 / 零点），unix 秒
+	State                   string  `json:"state"`
+	MaxSupply               string  `json:"max_supply"`
+	LendSupply              string  `json:"lend_supply"`
+	BorrowSupply            string  `json:"borrow_supply"`
+	Utilization             float64 `json:"utilization"` // lend_supply / max_supply
+	SettleAmountLend        string  `json:"settle_amount_lend"`
+	SettleAmountBorrow      string  `json:"settle_amount_borrow"`
+	FinishAmountLend        string  `json:"finish_amount_lend"`
+	FinishAmountBorrow      string  `json:"finish_amount_borrow"`
+	LiquidationAmountLend   string  `json:"liquidation_amount_lend"`
+	LiquidationAmountBorrow string  `json:"liquidation_amount_borrow"`
+	LendTokenPrice          string  `json:"lend_token_price"`
+	BorrowTokenPrice        string  `json:"borrow_token_price"`
+}
@@ -0,0 +1,40 @@
+package response
+
+// 钱包在一个池子里的持仓，金额都是代币最小单位的十进制字符串
+type UserPosition struct {
+	PoolId      int            `json:"pool_id"` // 库里的 pool_id，合约 pid+1
+	State       string         `json:"state"`   // 0 match / 1 execution / 2 finish / 3 liquidation / 4 undone
+	SettleTime  string         `json:"settle_time"`
+	EndTime     string         `json:"end_time"`
+	LendToken   string         `json:"lend_token"`
+	BorrowToken string         `json:"borrow_token"`
+	SpCoin      string         `json:"sp_coin"`
+	JpCoin      string         `json:"jp_coin"`
+	Lend        LendPosition   `json:"lend"`
+	Borrow      BorrowPosition `json:"borrow"`
+}
+
+// 出借方：userLendInfo + SP 余额
+type LendPosition struct {
+	StakeAmount  string `json:"stake_amount"`
+	RefundAmount string `json:"refund_amount"` // 已退还
+	HasNoRefund  bool   `json:"has_no_refund"` // true 表示已退款（含紧急提取）
+	HasNoClaim   bool   `json:"has_no_claim"`  // true 表示已领取 SP
+	SpBalance    string `json:"sp_balance"`
+	ClaimableSp  string `json:"claimable_sp"` // claimLend 可领取的 SP
+	Refundable   string `json:"refundable"`   // refundLend / emergencyLendWithdrawal 可退还的出借币
+	Withdrawable string `json:"withdrawable"` // 按当前 SP 余额 withdrawLend 可赎回的出借币
+}
+
+// 借款方：userBorrowInfo + JP 余额
+type BorrowPosition struct {
+	StakeAmount   string `json:"stake_amount"`
+	RefundAmount  string `json:"refund_amount"`
+	HasNoRefund   bool   `json:"has_no_refund"`
+	HasNoClaim    bool   `json:"has_no_claim"`
+	JpBalance     string `json:"jp_balance"`
+	ClaimableJp   string `json:"claimable_jp"`   // claimBorrow 可领取的 JP
+	ClaimableLoan string `json:"claimable_loan"` // claimBorrow 同时领取的借款（出借币）
+	Refundable    string `json:"refundable"`     // refundBorrow / emergencyBorrowWithdrawal 可退还的保证金
+	Withdrawable  string `json:"withdrawable"`   // 按当前 JP 余额 withdrawBorrow 可赎回的保证金
+}
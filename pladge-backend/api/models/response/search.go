@@ -3,6 +3,7 @@ package response
 import "pledge-backend/api/models"
 
 type Search struct {
-	Count int64         `json:"count"`
-	Rows  []models.Pool `json:"rows"`
+	Count      int64         `json:"count"`
+	Rows       []models.Pool `json:"rows"`
+	NextCursor string        `json:"next_cursor"` // 为空表示没有下一页
 }
@@ -0,0 +1,35 @@
+package response
+
+// webhook endpoint
+type Webhook struct {
+	Id             int64    `json:"id"`
+	Url            string   `json:"url"`
+	Secret         string   `json:"secret"` // 只在新建时返回，列表里打码
+	ChainId        int      `json:"chain_id"`
+	Events         []string `json:"events"`
+	PoolIds        []int64  `json:"pool_ids"`
+	Addresses      []string `json:"addresses"`
+	Enabled        bool     `json:"enabled"`
+	Failures       int      `json:"failures"` // 连续失败次数
+	DisabledReason string   `json:"disabled_reason"`
+	CreatedAt      int64    `json:"created_at"`
+}
+
+// webhook 投递记录
+type WebhookDelivery struct {
+	Id           int64  `json:"id"`
+	WebhookId    int64  `json:"webhook_id"`
+	ChainId      int    `json:"chain_id"`
+	TxHash       string `json:"tx_hash"`
+	LogIndex     int    `json:"log_index"`
+	Event        string `json:"event"`
+	Payload      string `json:"payload"`
+	Removed      bool   `json:"removed"` // 重组后的撤回
+	Status       string `json:"status"`  // pending / delivered / failed
+	Attempts     int    `json:"attempts"`
+	ResponseCode int    `json:"response_code"`
+	Error        string `json:"error"`
+	NextRetryAt  int64  `json:"next_retry_at"`
+	CreatedAt    int64  `json:"created_at"`
+	UpdatedAt    int64  `json:"updated_at"`
+}
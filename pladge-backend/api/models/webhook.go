@@ -0,0 +1,221 @@
+package models
+
+import (
+	"errors"
+	"pledge-backend/api/models/request"
+	"pledge-backend/db"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Webhook contract-listener 投递事件的 endpoint，配置存在 listener 库里
+type Webhook struct {
+	Id             int64
+	Url            string
+	Secret         string
+	ChainId        int
+	Events         []string
+	PoolIds        []int64
+	Addresses      []string
+	Enabled        bool
+	Failures       int
+	DisabledReason string
+	CreatedAt      time.Time
+}
+
+// WebhookDelivery contract-listener 的投递记录
+type WebhookDelivery struct {
+	Id           int64
+	WebhookId    int64
+	ChainId      int
+	TxHash       string
+	LogIndex     int
+	Event        string
+	Payload      string
+	Removed      bool
+	Status       string
+	Attempts     int
+	ResponseCode int
+	Error        string
+	NextRetryAt  time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+var ErrWebhookNotExist = errors.New("webhook not exist")
+
+func NewWebhook() *Webhook {
+	return &Webhook{}
+}
+
+func listenerDB() error {
+	if db.Listener == nil {
+		return errors.New("listener db not configured")
+	}
+	return nil
+}
+
+// Create 新建 webhook
+func (w *Webhook) Create(req *request.Webhook) error {
+	if err := listenerDB(); err != nil {
+		return err
+	}
+	err := db.Listener.QueryRow(
+		`INSERT INTO webhook (url, secret, chain_id, events, pool_ids, addresses)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, enabled, created_at`,
+		req.Url, req.Secret, req.ChainId, pq.Array(req.Events), pq.Array(req.PoolIds), pq.Array(req.Addresses),
+	).Scan(&w.Id, &w.Enabled, &w.CreatedAt)
+	if err != nil {
+		return errors.New("record insert err " + err.Error())
+	}
+	w.Url, w.Secret, w.ChainId = req.Url, req.Secret, req.ChainId
+	w.Events, w.PoolIds, w.Addresses = req.Events, req.PoolIds, req.Addresses
+	return nil
+}
+
+// Update 修改传了的字段，启用时清零连续失败次数；secret 为空保持不变
+func (w *Webhook) Update(req *request.WebhookUpdate) error {
+	if err := listenerDB(); err != nil {
+		return err
+	}
+
+	args := []interface{}{req.Id}
+	set := []string{"updated_at = now()"}
+	add := func(column string, v interface{}) {
+		args = append(args, v)
+		set = append(set, column+" = $"+strconv.Itoa(len(args)))
+	}
+	if req.Url != nil {
+		add("url", *req.Url)
+	}
+	if req.Secret != nil && *req.Secret != "" {
+		add("secret", *req.Secret)
+	}
+	if req.ChainId != nil {
+		add("chain_id", *req.ChainId)
+	}
+	if req.Events != nil {
+		add("events", pq.Array(*req.Events))
+	}
+	if req.PoolIds != nil {
+		add("pool_ids", pq.Array(*req.PoolIds))
+	}
+	if req.Addresses != nil {
+		add("addresses", pq.Array(*req.Addresses))
+	}
+	if req.Enabled != nil {
+		add("enabled", *req.Enabled)
+		if *req.Enabled {
+			set = append(set, "failures = 0", "disabled_reason = ''")
+		}
+	}
+
+	res, err := db.Listener.Exec(`UPDATE webhook SET `+strings.Join(set, ", ")+` WHERE id = $1`, args...)
+	if err != nil {
+		return errors.New("record update err " + err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWebhookNotExist
+	}
+	return nil
+}
+
+// Delete 在一个事务里删除 webhook 及其投递记录
+func (w *Webhook) Delete(id int64) error {
+	if err := listenerDB(); err != nil {
+		return err
+	}
+	tx, err := db.Listener.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.Exec(`DELETE FROM webhook_delivery WHERE webhook_id = $1`, id)
+	if err != nil {
+		return errors.New("record delete err " + err.Error())
+	}
+	res, err := tx.Exec(`DELETE FROM webhook WHERE id = $1`, id)
+	if err != nil {
+		return errors.New("record delete err " + err.Error())
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWebhookNotExist
+	}
+	return tx.Commit()
+}
+
+// List 全部 webhook
+func (w *Webhook) List() ([]Webhook, error) {
+	if err := listenerDB(); err != nil {
+		return nil, err
+	}
+	rows, err := db.Listener.Query(
+		`SELECT id, url, secret, chain_id, events, pool_ids, addresses, enabled, failures, disabled_reason, created_at
+		FROM webhook ORDER BY id`,
+	)
+	if err != nil {
+		return nil, errors.New("record select err " + err.Error())
+	}
+	defer rows.Close()
+
+	var list []Webhook
+	for rows.Next() {
+		var h Webhook
+		err = rows.Scan(&h.Id, &h.Url, &h.Secret, &h.ChainId, pq.Array(&h.Events), pq.Array(&h.PoolIds),
+			pq.Array(&h.Addresses), &h.Enabled, &h.Failures, &h.DisabledReason, &h.CreatedAt)
+		if err != nil {
+			return nil, errors.New("record select err " + err.Error())
+		}
+		list = append(list, h)
+	}
+	return list, rows.Err()
+}
+
+// Deliveries 分页查询投递记录，最新的在前
+func (w *Webhook) Deliveries(req *request.WebhookDeliveries) (error, int64, []WebhookDelivery) {
+	if err := listenerDB(); err != nil {
+		return err, 0, nil
+	}
+
+	var total int64
+	err := db.Listener.QueryRow(
+		`SELECT count(*) FROM webhook_delivery WHERE ($1 = 0 OR webhook_id = $1) AND ($2 = '' OR status = $2)`,
+		req.WebhookId, req.Status,
+	).Scan(&total)
+	if err != nil {
+		return errors.New("record select err " + err.Error()), 0, nil
+	}
+
+	rows, err := db.Listener.Query(
+		`SELECT id, webhook_id, chain_id, tx_hash, log_index, event_name, payload, removed, status, attempts,
+			response_code, error, next_retry_at, created_at, updated_at
+		FROM webhook_delivery WHERE ($1 = 0 OR webhook_id = $1) AND ($2 = '' OR status = $2)
+		ORDER BY id DESC LIMIT $3 OFFSET $4`,
+		req.WebhookId, req.Status, req.PageSize, (req.Page-1)*req.PageSize,
+	)
+	if err != nil {
+		return errors.New("record select err " + err.Error()), 0, nil
+	}
+	defer rows.Close()
+
+	var list []WebhookDelivery
+	for rows.Next() {
+		var d WebhookDelivery
+		err = rows.Scan(&d.Id, &d.WebhookId, &d.ChainId, &d.TxHash, &d.LogIndex, &d.Event, &d.Payload, &d.Removed, &d.Status,
+			&d.Attempts, &d.ResponseCode, &d.Error, &d.NextRetryAt, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return errors.New("record select err " + err.Error()), 0, nil
+		}
+		list = append(list, d)
+	}
+	if err = rows.Err(); err != nil {
+		return errors.New("record select err " + err.Error()), 0, nil
+	}
+	return nil, total, list
+}
@@ -19,6 +19,7 @@ func main() {
 	//  1. 初始化存储层：建立 MySQL 和 Redis 的物理连接
 	db.InitMysql()
 	db.InitRedis()
+	db.InitListenerDB()
 	// 2. 自动同步数据库表结构：根据 models 定义的 struct 自动创建/更新表
 	models.InitTable()
 
@@ -18,6 +18,8 @@ func InitRoute(e *gin.Engine) *gin.Engine {
 	v2Group.GET("/poolBaseInfo", poolController.PoolBaseInfo)                                   //pool base information
 	v2Group.GET("/poolDataInfo", poolController.PoolDataInfo)                                   //pool data information
 	v2Group.GET("/token", poolController.TokenList)                                             //pool token information
+	v2Group.GET("/pool/:chainId/:poolId", poolController.PoolDetail)                            //single pool detail
+	v2Group.GET("/pool/:chainId/:poolId/history", poolController.PoolHistory)                   //pool hourly / daily snapshots
 	v2Group.POST("/pool/debtTokenList", middlewares.CheckToken(), poolController.DebtTokenList) //pool debtTokenList
 	v2Group.POST("/pool/search", middlewares.CheckToken(), poolController.Search)               //pool search
 
@@ -27,8 +29,19 @@ func InitRoute(e *gin.Engine) *gin.Engine {
 
 	// pledge-defi admin backend
 	multiSignPoolController := controllers.MultiSignPoolController{}
-	v2Group.POST("/pool/setMultiSign", middlewares.CheckToken(), multiSignPoolController.SetMultiSign) //multi-sign set
-	v2Group.POST("/pool/getMultiSign", middlewares.CheckToken(), multiSignPoolController.GetMultiSign) //multi-sign get
+	v2Group.POST("/pool/setMultiSign", middlewares.CheckToken(), multiSignPoolController.SetMultiSign)              //multi-sign set
+	v2Group.POST("/pool/getMultiSign", middlewares.CheckToken(), multiSignPoolController.GetMultiSign)              //multi-sign get
+	v2Group.POST("/pool/multiSign/applications", middlewares.CheckToken(), multiSignPoolController.GetApplications) //multi-sign on-chain applications
+
+	webhookController := controllers.WebhookController{}
+	v2Group.POST("/webhook/create", middlewares.CheckToken(), webhookController.Create)         //webhook create
+	v2Group.POST("/webhook/update", middlewares.CheckToken(), webhookController.Update)         //webhook update / enable / disable
+	v2Group.POST("/webhook/delete", middlewares.CheckToken(), webhookController.Delete)         //webhook delete
+	v2Group.POST("/webhook/list", middlewares.CheckToken(), webhookController.List)             //webhook list
+	v2Group.POST("/webhook/deliveries", middlewares.CheckToken(), webhookController.Deliveries) //webhook delivery log
+
+	positionController := controllers.PositionController{}
+	v2Group.GET("/user/:address/positions", positionController.Positions) //wallet positions in every pool
 
 	userController := controllers.UserController{}
 	v2Group.POST("/user/login", userController.Login)                             // login
@@ -46,3 +46,43 @@ func (c *MutiSignService) GetMultiSign(mutiSign *response.MultiSign, chainId int
 	mutiSign.MultiSignAccount = multiSignAccount
 	return statecode.CommonSuccess, nil
 }
+
+// GetApplications 链上多签申请及签名进度
+func (c *MutiSignService) GetApplications(req *request.MultiSignApplications) (int, int64, []response.MultiSignApplication, error) {
+	//listener db get
+	err, total, list := models.NewMultiSignApplication().Pagination(req)
+	if err != nil {
+		return statecode.CommonErrServerErr, 0, nil, err
+	}
+	result := make([]response.MultiSignApplication, 0, len(list))
+	for _, a := range list {
+		signatures, err := a.SignList(req.ChainId)
+		if err != nil {
+			return statecode.CommonErrServerErr, 0, nil, err
+		}
+		signers := []string{}
+		revoked := []string{}
+		for _, s := range signatures {
+			if s.Signed {
+				signers = append(signers, s.Signer)
+			} else {
+				revoked = append(revoked, s.Signer)
+			}
+		}
+		result = append(result, response.MultiSignApplication{
+			MsgHash:     a.MsgHash,
+			Index:       a.Index,
+			Applicant:   a.Applicant,
+			Target:      a.Target,
+			Threshold:   a.Threshold,
+			Signatures:  a.Signatures,
+			Status:      a.Status,
+			Signers:     signers,
+			Revoked:     revoked,
+			TxHash:      a.TxHash,
+			BlockNumber: a.BlockNumber,
+			CreatedAt:   a.CreatedAt.Unix(),
+		})
+	}
+	return statecode.CommonSuccess, total, result, nil
+}
@@ -1,9 +1,18 @@
 package services
 
 import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"pledge-backend/api/analytics"
 	"pledge-backend/api/common/statecode"
 	"pledge-backend/api/models"
+	"pledge-backend/api/models/request"
+	"pledge-backend/api/models/response"
 	"pledge-backend/log"
+	scheduleModels "pledge-backend/schedule/models"
+	"pledge-backend/utils"
+	"time"
 )
 
 type poolService struct{}
@@ -31,3 +40,163 @@ func (s *poolService) PoolDataInfo(chainId int, result *[]models.PoolDataInfoRes
 	}
 	return statecode.CommonSuccess
 }
+
+// 合约 PoolState 枚举
+var poolStateNames = []string{"match", "execution", "finish", "liquidation", "undone"}
+
+// PoolDetail 单个池子：poolbases、pooldata、借贷币价格、状态名、倒计时和手续费
+func (s *poolService) PoolDetail(chainId, poolId int, result *response.PoolDetail) (int, error) {
+
+	err, detail := models.NewPool().Detail(chainId, poolId)
+	if errors.Is(err, models.ErrPoolNotExist) {
+		return statecode.PoolNotExist, err
+	}
+	if err != nil {
+		return statecode.CommonErrServerErr, err
+	}
+
+	b := detail.Base
+	now := time.Now().Unix()
+	stateCode := utils.StringToInt(b.State)
+	state := ""
+	if stateCode >= 0 && stateCode < len(poolStateNames) {
+		state = poolStateNames[stateCode]
+	}
+
+	var lendInfo scheduleModels.LendToken
+	_ = json.Unmarshal([]byte(b.LendTokenInfo), &lendInfo)
+	var borrowInfo scheduleModels.BorrowToken
+	_ = json.Unmarshal([]byte(b.BorrowTokenInfo), &borrowInfo)
+
+	*result = response.PoolDetail{
+		PoolId:                 b.PoolId,
+		ChainId:                chainId,
+		State:                  state,
+		StateCode:              stateCode,
+		SettleTime:             utils.StringToInt64(b.SettleTime),
+		EndTime:                utils.StringToInt64(b.EndTime),
+		SettleCountdown:        max(utils.StringToInt64(b.SettleTime)-now, 0),
+		EndCountdown:           max(utils.StringToInt64(b.EndTime)-now, 0),
+		InterestRate:           b.InterestRate,
+		MaxSupply:              b.MaxSupply,
+		LendSupply:             b.LendSupply,
+		BorrowSupply:           b.BorrowSupply,
+		MartgageRate:           b.MartgageRate,
+		AutoLiquidateThreshold: b.AutoLiquidateThreshold,
+		SpCoin:                 b.SpCoin,
+		JpCoin:                 b.JpCoin,
+		LendToken:              poolToken(b.LendToken, detail.LendToken, lendInfo.TokenName, lendInfo.TokenLogo, lendInfo.TokenPrice),
+		BorrowToken:            poolToken(b.BorrowToken, detail.BorrowToken, borrowInfo.TokenName, borrowInfo.TokenLogo, borrowInfo.TokenPrice),
+		Fees: response.PoolFees{
+			LendFee:   lendInfo.LendFee,
+			BorrowFee: borrowInfo.BorrowFee,
+		},
+		Data: response.PoolDetailData{
+			SettleAmountLend:        "0",
+			SettleAmountBorrow:      "0",
+			FinishAmountLend:        "0",
+			FinishAmountBorrow:      "0",
+			LiquidationAmountLend:   "0",
+			LiquidationAmountBorrow: "0",
+		},
+	}
+	if d := detail.Data; d != nil {
+		result.Data = response.PoolDetailData{
+			SettleAmountLend:        d.SettleAmountLend,
+			SettleAmountBorrow:      d.SettleAmountBorrow,
+			FinishAmountLend:        d.FinishAmountLend,
+			FinishAmountBorrow:      d.FinishAmountBorrow,
+			LiquidationAmountLend:   d.LiquidationAmounLend,
+			LiquidationAmountBorrow: d.LiquidationAmounBorrow,
+			UpdatedAt:               d.UpdatedAt,
+		}
+	}
+	result.Analytics = analytics.Compute(analytics.Pool{
+		State:                  b.State,
+		SettleTime:             b.SettleTime,
+		EndTime:                b.EndTime,
+		InterestRate:           b.InterestRate,
+		MartgageRate:           b.MartgageRate,
+		AutoLiquidateThreshold: b.AutoLiquidateThreshold,
+		MaxSupply:              b.MaxSupply,
+		LendSupply:             b.LendSupply,
+		BorrowSupply:           b.BorrowSupply,
+		SettleAmountLend:       result.Data.SettleAmountLend,
+		SettleAmountBorrow:     result.Data.SettleAmountBorrow,
+		LendFee:                lendInfo.LendFee,
+		BorrowFee:              borrowInfo.BorrowFee,
+		LendTokenPrice:         result.LendToken.Price,
+		BorrowTokenPrice:       result.BorrowToken.Price,
+	})
+
+	return statecode.CommonSuccess, nil
+}
+
+// token_info 里没有的币用池子同步时记下的名称、logo、价格
+func poolToken(address string, t scheduleModels.TokenInfo, symbol, logo, price string) response.PoolToken {
+	res := response.PoolToken{
+		Address:        address,
+		Symbol:         t.Symbol,
+		Logo:           t.Logo,
+		Decimals:       t.Decimals,
+		Price:          t.Price,
+		PriceUpdatedAt: t.UpdatedAt,
+	}
+	if res.Symbol == "" {
+		res.Symbol = symbol
+	}
+	if res.Logo == "" {
+		res.Logo = logo
+	}
+	if res.Price == "" {
+		res.Price = price
+	}
+	return res
+}
+
+// PoolHistory 池子在 [from, to] 内的小时 / 日快照
+func (s *poolService) PoolHistory(req *request.PoolHistory, result *response.PoolHistory) (int, error) {
+
+	snapshots, err := scheduleModels.NewPoolSnapshot().History(utils.IntToString(req.ChainId), req.PoolId, req.Interval, req.From, req.To)
+	if err != nil {
+		return statecode.CommonErrServerErr, err
+	}
+
+	*result = response.PoolHistory{
+		PoolId:   req.PoolId,
+		ChainId:  req.ChainId,
+		Interval: req.Interval,
+		Points:   make([]response.PoolHistoryPoint, 0, len(snapshots)),
+	}
+	for _, p := range snapshots {
+		result.Points = append(result.Points, response.PoolHistoryPoint{
+			Time:                    p.Bucket,
+			State:                   p.State,
+			MaxSupply:               p.MaxSupply,
+			LendSupply:              p.LendSupply,
+			BorrowSupply:            p.BorrowSupply,
+			Utilization:             ratio(p.LendSupply, p.MaxSupply),
+			SettleAmountLend:        p.SettleAmountLend,
+			SettleAmountBorrow:      p.SettleAmountBorrow,
+			FinishAmountLend:        p.FinishAmountLend,
+			FinishAmountBorrow:      p.FinishAmountBorrow,
+			LiquidationAmountLend:   p.LiquidationAmountLend,
+			LiquidationAmountBorrow: p.LiquidationAmountBorrow,
+			LendTokenPrice:          p.LendTokenPrice,
+			BorrowTokenPrice:        p.BorrowTokenPrice,
+		})
+	}
+
+	return statecode.CommonSuccess, nil
+}
+
+// a / b 的近似值，b 为 0 或非法时为 0
+func ratio(a, b string) float64 {
+	x, ok1 := new(big.Float).SetString(a)
+	y, ok2 := new(big.Float).SetString(b)
+	if !ok1 || !ok2 || y.Sign() == 0 {
+		return 0
+	}
+	r, _ := new(big.Float).Quo(x, y).Float64()
+	return r
+}
@@ -0,0 +1,388 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"pledge-backend/api/common/statecode"
+	"pledge-backend/api/models"
+	"pledge-backend/api/models/request"
+	"pledge-backend/api/models/response"
+	"pledge-backend/config"
+	abifile "pledge-backend/contract/abi"
+	"pledge-backend/contract/bindings"
+	"pledge-backend/db"
+	"pledge-backend/log"
+	"pledge-backend/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	positionsCacheSeconds = 300 // 兜底过期时间，contract-listener 收到该用户的事件会提前删除
+	positionsConcurrency  = 8   // 每个请求同时读链的池子数
+	positionsReadTimeout  = 20 * time.Second
+)
+
+// PledgePool 合约里的 calDecimal / baseDecimal
+var (
+	calDecimal  = big.NewInt(1e18)
+	baseDecimal = big.NewInt(1e8)
+)
+
+// 用户在一个池子里的链上原始数据，按 (链, 地址) 缓存在 Redis
+// 可领取 / 可退还金额还取决于池子状态和时间，每次按库里最新的 poolbases / pooldata 计算，不进缓存
+type chainPosition struct {
+	LendStake         string `json:"lend_stake"`
+	LendRefund        string `json:"lend_refund"`
+	LendHasNoRefund   bool   `json:"lend_has_no_refund"`
+	LendHasNoClaim    bool   `json:"lend_has_no_claim"`
+	BorrowStake       string `json:"borrow_stake"`
+	BorrowRefund      string `json:"borrow_refund"`
+	BorrowHasNoRefund bool   `json:"borrow_has_no_refund"`
+	BorrowHasNoClaim  bool   `json:"borrow_has_no_claim"`
+	SpBalance         string `json:"sp_balance"`
+	JpBalance         string `json:"jp_balance"`
+}
+
+type positionService struct{}
+
+func NewPosition() *positionService {
+	return &positionService{}
+}
+
+// UserPositions 钱包在链上每个池子的持仓
+func (s *positionService) UserPositions(req *request.UserPositions, result *[]response.UserPosition) (int, error) {
+
+	pools, err := models.NewPositionPool().List(req.ChainId)
+	if err != nil {
+		return statecode.CommonErrServerErr, err
+	}
+
+	prefix := config.Config.Listener.PositionsPrefix
+	key := fmt.Sprintf("%s:%d:%s", prefix, req.ChainId, strings.ToLower(req.Address))
+
+	cached := make(map[int]chainPosition)
+	if prefix != "" {
+		if b, _ := db.RedisGet(key); len(b) > 0 {
+			_ = json.Unmarshal(b, &cached)
+		}
+	}
+
+	// 缓存之后新建的池子不在里面，整体重新读链
+	for _, p := range pools {
+		if _, ok := cached[p.PoolId]; ok {
+			continue
+		}
+		cached, err = s.readChain(req, pools)
+		if err != nil {
+			return statecode.CommonErrServerErr, err
+		}
+		if prefix != "" {
+			if err := db.RedisSet(key, cached, positionsCacheSeconds); err != nil {
+				log.Logger.Error(err.Error())
+			}
+		}
+		break
+	}
+
+	now := time.Now().Unix()
+	*result = make([]response.UserPosition, 0, len(pools))
+	for _, p := range pools {
+		*result = append(*result, userPosition(&p, cached[p.PoolId], now))
+	}
+	return statecode.CommonSuccess, nil
+}
+
+// 所有池子的 userLendInfo / userBorrowInfo 和 SP/JP 余额，都读同一个区块
+func (s *positionService) readChain(req *request.UserPositions, pools []models.PositionPool) (map[int]chainPosition, error) {
+
+	network, contract := positionNet(req.ChainId)
+	if network == "" || contract == "" {
+		return nil, fmt.Errorf("chain %d not configured", req.ChainId)
+	}
+
+	client, err := positionClient(network)
+	if err != nil {
+		return nil, err
+	}
+	pledgePool, err := bindings.NewPledgePoolToken(common.HexToAddress(contract), client)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), positionsReadTimeout)
+	defer cancel()
+
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opts := &bind.CallOpts{Context: ctx, BlockNumber: new(big.Int).SetUint64(head)}
+	user := common.HexToAddress(req.Address)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		sem      = make(chan struct{}, positionsConcurrency)
+		res      = make(map[int]chainPosition, len(pools))
+	)
+	for _, p := range pools {
+		wg.Add(1)
+		go func(p models.PositionPool) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			pos, err := readPosition(opts, client, pledgePool, user, &p)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("pool %d: %w", p.PoolId, err)
+				}
+				return
+			}
+			res[p.PoolId] = pos
+		}(p)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return res, nil
+}
+
+func readPosition(opts *bind.CallOpts, client *ethclient.Client, pledgePool *bindings.PledgePoolToken,
+	user common.Address, p *models.PositionPool) (chainPosition, error) {
+
+	pid := big.NewInt(int64(p.PoolId - 1))
+
+	lend, err := pledgePool.PledgePoolTokenCaller.UserLendInfo(opts, user, pid)
+	if err != nil {
+		return chainPosition{}, err
+	}
+	borrow, err := pledgePool.PledgePoolTokenCaller.UserBorrowInfo(opts, user, pid)
+	if err != nil {
+		return chainPosition{}, err
+	}
+	sp, err := debtBalance(opts, client, p.SpCoin, user)
+	if err != nil {
+		return chainPosition{}, err
+	}
+	jp, err := debtBalance(opts, client, p.JpCoin, user)
+	if err != nil {
+		return chainPosition{}, err
+	}
+
+	return chainPosition{
+		LendStake:         lend.StakeAmount.String(),
+		LendRefund:        lend.RefundAmount.String(),
+		LendHasNoRefund:   lend.HasNoRefund,
+		LendHasNoClaim:    lend.HasNoClaim,
+		BorrowStake:       borrow.StakeAmount.String(),
+		BorrowRefund:      borrow.RefundAmount.String(),
+		BorrowHasNoRefund: borrow.HasNoRefund,
+		BorrowHasNoClaim:  borrow.HasNoClaim,
+		SpBalance:         sp,
+		JpBalance:         jp,
+	}, nil
+}
+
+var (
+	erc20Once sync.Once
+	erc20Abi  abi.ABI
+	erc20Err  error
+)
+
+// SP/JP 余额：和 userLendInfo / userBorrowInfo 读同一个区块的 balanceOf
+// 不用 contract-listener 同步的余额，它落后确认深度个区块，和领取状态对不上
+func debtBalance(opts *bind.CallOpts, client *ethclient.Client, token string, user common.Address) (string, error) {
+
+	if !common.IsHexAddress(token) || common.HexToAddress(token) == (common.Address{}) {
+		return "0", nil
+	}
+
+	erc20Once.Do(func() {
+		var abiStr string
+		abiStr, erc20Err = abifile.GetAbiByToken("erc20")
+		if erc20Err == nil {
+			erc20Abi, erc20Err = abi.JSON(strings.NewReader(abiStr))
+		}
+	})
+	if erc20Err != nil {
+		return "", erc20Err
+	}
+
+	contract := bind.NewBoundContract(common.HexToAddress(token), erc20Abi, client, client, client)
+	res := make([]interface{}, 0)
+	if err := contract.Call(opts, &res, "balanceOf", user); err != nil {
+		return "", err
+	}
+	balance, ok := res[0].(*big.Int)
+	if !ok {
+		return "", errors.New("balanceOf: unexpected result")
+	}
+	return balance.String(), nil
+}
+
+// 节点 RPC 和 PledgePool 地址
+func positionNet(chainId int) (string, string) {
+	switch utils.IntToString(chainId) {
+	case config.Config.TestNet.ChainId:
+		return config.Config.TestNet.NetUrl, config.Config.TestNet.PledgePoolToken
+	case config.Config.MainNet.ChainId:
+		return config.Config.MainNet.NetUrl, config.Config.MainNet.PledgePoolToken
+	}
+	return "", ""
+}
+
+// 节点连接按 network 复用
+var positionClients sync.Map
+
+func positionClient(network string) (*ethclient.Client, error) {
+	if c, ok := positionClients.Load(network); ok {
+		return c.(*ethclient.Client), nil
+	}
+	c, err := ethclient.Dial(network)
+	if err != nil {
+		return nil, err
+	}
+	conn, _ := positionClients.LoadOrStore(network, c)
+	return conn.(*ethclient.Client), nil
+}
+
+// 按 PledgePool 合约的算法计算当前可领取 / 可退还 / 可赎回的金额
+func userPosition(p *models.PositionPool, c chainPosition, now int64) response.UserPosition {
+
+	state := p.State
+	settleTime := utils.StringToInt64(p.SettleTime)
+	endTime := utils.StringToInt64(p.EndTime)
+
+	lendSupply := toBig(p.LendSupply)
+	borrowSupply := toBig(p.BorrowSupply)
+	settleLend := toBig(p.SettleAmountLend)
+	settleBorrow := toBig(p.SettleAmountBorrow)
+
+	// refund / claim：执行中、完成、清算，且过了结算时间
+	settled := (state == "1" || state == "2" || state == "3") && now > settleTime
+	// withdraw：完成后过了结束时间，或清算后过了结算时间
+	finished := state == "2" && now > endTime
+	liquidated := state == "3" && now > settleTime
+	// emergency：池子未成立，退还全部质押
+	undone := state == "4"
+
+	lend := response.LendPosition{
+		StakeAmount:  orZero(c.LendStake),
+		RefundAmount: orZero(c.LendRefund),
+		HasNoRefund:  c.LendHasNoRefund,
+		HasNoClaim:   c.LendHasNoClaim,
+		SpBalance:    orZero(c.SpBalance),
+		ClaimableSp:  "0",
+		Refundable:   "0",
+		Withdrawable: "0",
+	}
+	stake := toBig(c.LendStake)
+	if stake.Sign() > 0 && lendSupply.Sign() > 0 {
+		share := mulDiv(stake, calDecimal, lendSupply)
+		if settled && !c.LendHasNoClaim {
+			lend.ClaimableSp = mulDiv(settleLend, share, calDecimal).String()
+		}
+		if settled && !c.LendHasNoRefund && lendSupply.Cmp(settleLend) > 0 {
+			lend.Refundable = mulDiv(new(big.Int).Sub(lendSupply, settleLend), share, calDecimal).String()
+		}
+		if undone && !c.LendHasNoRefund {
+			lend.Refundable = stake.String()
+		}
+	}
+	if sp := toBig(c.SpBalance); sp.Sign() > 0 && settleLend.Sign() > 0 && (finished || liquidated) {
+		spShare := mulDiv(sp, calDecimal, settleLend)
+		amount := toBig(p.FinishAmountLend)
+		if liquidated {
+			amount = toBig(p.LiquidationAmounLend)
+		}
+		lend.Withdrawable = mulDiv(amount, spShare, calDecimal).String()
+	}
+
+	borrow := response.BorrowPosition{
+		StakeAmount:   orZero(c.BorrowStake),
+		RefundAmount:  orZero(c.BorrowRefund),
+		HasNoRefund:   c.BorrowHasNoRefund,
+		HasNoClaim:    c.BorrowHasNoClaim,
+		JpBalance:     orZero(c.JpBalance),
+		ClaimableJp:   "0",
+		ClaimableLoan: "0",
+		Refundable:    "0",
+		Withdrawable:  "0",
+	}
+	// JP 总量 = 实际出借额 * 质押率
+	totalJp := mulDiv(settleLend, toBig(p.MartgageRate), baseDecimal)
+	stake = toBig(c.BorrowStake)
+	if stake.Sign() > 0 && borrowSupply.Sign() > 0 {
+		share := mulDiv(stake, calDecimal, borrowSupply)
+		if settled && !c.BorrowHasNoClaim {
+			borrow.ClaimableJp = mulDiv(totalJp, share, calDecimal).String()
+			borrow.ClaimableLoan = mulDiv(settleLend, share, calDecimal).String()
+		}
+		if settled && !c.BorrowHasNoRefund && borrowSupply.Cmp(settleBorrow) > 0 {
+			borrow.Refundable = mulDiv(new(big.Int).Sub(borrowSupply, settleBorrow), share, calDecimal).String()
+		}
+		if undone && !c.BorrowHasNoRefund {
+			borrow.Refundable = stake.String()
+		}
+	}
+	if jp := toBig(c.JpBalance); jp.Sign() > 0 && totalJp.Sign() > 0 && (finished || liquidated) {
+		jpShare := mulDiv(jp, calDecimal, totalJp)
+		amount := toBig(p.FinishAmountBorrow)
+		if liquidated {
+			amount = toBig(p.LiquidationAmounBorrow)
+		}
+		borrow.Withdrawable = mulDiv(jpShare, amount, calDecimal).String()
+	}
+
+	return response.UserPosition{
+		PoolId:      p.PoolId,
+		State:       p.State,
+		SettleTime:  p.SettleTime,
+		EndTime:     p.EndTime,
+		LendToken:   p.LendToken,
+		BorrowToken: p.BorrowToken,
+		SpCoin:      p.SpCoin,
+		JpCoin:      p.JpCoin,
+		Lend:        lend,
+		Borrow:      borrow,
+	}
+}
+
+// a * b / c，和合约一样向下取整
+func mulDiv(a, b, c *big.Int) *big.Int {
+	if c.Sign() == 0 {
+		return new(big.Int)
+	}
+	r := new(big.Int).Mul(a, b)
+	return r.Quo(r, c)
+}
+
+// 库里的十进制字符串，空或非法按 0
+func toBig(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return new(big.Int)
+	}
+	return n
+}
+
+func orZero(s string) string {
+	return toBig(s).String()
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"pledge-backend/api/models"
+	"testing"
+)
+
+// 池子：出借 1000、借款保证金 500，结算后 settleAmountLend 800 / settleAmountBorrow 400，质押率 200%
+// 用户出借 250（份额 25%）、质押 500 里的 100（份额 20%）
+//
+//	claimLend      SP = 800 * 25% = 200
+//	refundLend        = (1000 - 800) * 25% = 50
+//	claimBorrow    JP = 800 * 200% * 20% = 320，借到 800 * 20% = 160
+//	refundBorrow      = (500 - 400) * 20% = 20
+//	withdrawLend      = finishAmountLend 840 * (200 / 800) = 210，清算时 820 * 25% = 205
+//	withdrawBorrow    = finishAmountBorrow 300 * (320 / 1600) = 60，清算时 100 * 20% = 20
+func testPositionPool(state string) *models.PositionPool {
+	return &models.PositionPool{
+		PoolId:                 1,
+		State:                  state,
+		SettleTime:             "1000",
+		EndTime:                "2000",
+		LendSupply:             "1000",
+		BorrowSupply:           "500",
+		MartgageRate:           "200000000",
+		SettleAmountLend:       "800",
+		SettleAmountBorrow:     "400",
+		FinishAmountLend:       "840",
+		FinishAmountBorrow:     "300",
+		LiquidationAmounLend:   "820",
+		LiquidationAmounBorrow: "100",
+	}
+}
+
+func testChainPosition() chainPosition {
+	return chainPosition{
+		LendStake:   "250",
+		BorrowStake: "100",
+		SpBalance:   "200",
+		JpBalance:   "320",
+	}
+}
+
+func TestUserPosition(t *testing.T) {
+
+	type want struct {
+		claimableSp, lendRefundable, lendWithdrawable                    string
+		claimableJp, claimableLoan, borrowRefundable, borrowWithdrawable string
+	}
+	none := want{"0", "0", "0", "0", "0", "0", "0"}
+	claims := want{"200", "50", "0", "320", "160", "20", "0"}
+
+	tests := []struct {
+		name  string
+		state string
+		now   int64
+		pool  func(p *models.PositionPool)
+		chain func(c *chainPosition)
+		want  want
+	}{
+		{name: "match", state: "0", now: 1500, want: none},
+		{name: "execution at settle time", state: "1", now: 1000, want: none},
+		{name: "execution after settle time", state: "1", now: 1001, want: claims},
+		{
+			name: "already claimed", state: "1", now: 1001,
+			chain: func(c *chainPosition) { c.LendHasNoClaim, c.BorrowHasNoClaim = true, true },
+			want:  want{"0", "50", "0", "0", "0", "20", "0"},
+		},
+		{
+			name: "already refunded", state: "1", now: 1001,
+			chain: func(c *chainPosition) { c.LendHasNoRefund, c.BorrowHasNoRefund = true, true },
+			want:  want{"200", "0", "0", "320", "160", "0", "0"},
+		},
+		{
+			name: "claimed and refunded", state: "1", now: 1001,
+			chain: func(c *chainPosition) {
+				c.LendHasNoClaim, c.BorrowHasNoClaim = true, true
+				c.LendHasNoRefund, c.BorrowHasNoRefund = true, true
+			},
+			want: none,
+		},
+		{
+			name: "fully matched, nothing to refund", state: "1", now: 1001,
+			pool: func(p *models.PositionPool) { p.SettleAmountLend, p.SettleAmountBorrow = "1000", "500" },
+			want: want{"250", "0", "0", "400", "200", "0", "0"},
+		},
+		{name: "finish at end time", state: "2", now: 2000, want: claims},
+		{name: "finish after end time", state: "2", now: 2001, want: want{"200", "50", "210", "320", "160", "20", "60"}},
+		{name: "liquidation at settle time", state: "3", now: 1000, want: none},
+		{name: "liquidation after settle time", state: "3", now: 1001, want: want{"200", "50", "205", "320", "160", "20", "20"}},
+		{
+			name: "liquidation without sp / jp", state: "3", now: 1001,
+			chain: func(c *chainPosition) { c.SpBalance, c.JpBalance = "0", "0" },
+			want:  claims,
+		},
+		{
+			name: "undone emergency withdrawal", state: "4", now: 1500,
+			pool: func(p *models.PositionPool) { p.SettleAmountLend, p.SettleAmountBorrow = "1000", "500" },
+			want: want{"0", "250", "0", "0", "0", "100", "0"},
+		},
+		{
+			name: "undone already withdrawn", state: "4", now: 1500,
+			chain: func(c *chainPosition) { c.LendHasNoRefund, c.BorrowHasNoRefund = true, true },
+			want:  none,
+		},
+		{
+			name: "no stake", state: "1", now: 1001,
+			chain: func(c *chainPosition) { c.LendStake, c.BorrowStake = "", "" },
+			want:  none,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testPositionPool(tt.state)
+			if tt.pool != nil {
+				tt.pool(p)
+			}
+			c := testChainPosition()
+			if tt.chain != nil {
+				tt.chain(&c)
+			}
+
+			got := userPosition(p, c, tt.now)
+
+			gotWant := want{
+				got.Lend.ClaimableSp, got.Lend.Refundable, got.Lend.Withdrawable,
+				got.Borrow.ClaimableJp, got.Borrow.ClaimableLoan, got.Borrow.Refundable, got.Borrow.Withdrawable,
+			}
+			if gotWant != tt.want {
+				t.Errorf("got %+v, want %+v", gotWant, tt.want)
+			}
+		})
+	}
+}
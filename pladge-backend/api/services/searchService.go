@@ -1,7 +1,8 @@
 package services
 
 import (
-	"fmt"
+	"encoding/base64"
+	"encoding/json"
 	"pledge-backend/api/common/statecode"
 	"pledge-backend/api/models"
 	"pledge-backend/api/models/request"
@@ -14,19 +15,28 @@ func NewSearch() *SearchService {
 	return &SearchService{}
 }
 
-func (c *SearchService) Search(req *request.Search) (int, int64, []models.Pool) {
+// Search 筛选条件已经过 validate 校验并补好默认值，返回的 next 为下一页游标
+func (c *SearchService) Search(req *request.Search) (int, int64, []models.Pool, string) {
 
-	whereCondition := fmt.Sprintf(`chain_id='%v'`, req.ChainID)
-	if req.LendTokenSymbol != "" {
-		whereCondition += fmt.Sprintf(` and lend_token_symbol='%v'`, req.LendTokenSymbol)
+	var cursor *models.SearchCursor
+	if req.Cursor != "" {
+		cursor = &models.SearchCursor{}
+		b, err := base64.RawURLEncoding.DecodeString(req.Cursor)
+		if err != nil || json.Unmarshal(b, cursor) != nil {
+			return statecode.PoolSearchErr, 0, nil, ""
+		}
 	}
-	if req.State != "" {
-		whereCondition += fmt.Sprintf(` and state='%v'`, req.State)
-	}
-	err, total, data := models.NewPool().Pagination(req, whereCondition)
+
+	err, total, data, next := models.NewPool().Pagination(req, cursor)
 	if err != nil {
 		log.Logger.Error(err.Error())
-		return statecode.CommonErrServerErr, 0, nil
+		return statecode.CommonErrServerErr, 0, nil, ""
+	}
+
+	nextCursor := ""
+	if next != nil {
+		b, _ := json.Marshal(next)
+		nextCursor = base64.RawURLEncoding.EncodeToString(b)
 	}
-	return 0, total, data
+	return 0, total, data, nextCursor
 }
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"pledge-backend/api/common/statecode"
+	"pledge-backend/api/models"
+	"pledge-backend/api/models/request"
+	"pledge-backend/api/models/response"
+)
+
+type WebhookService struct{}
+
+func NewWebhook() *WebhookService {
+	return &WebhookService{}
+}
+
+// Create 新建 webhook，没传 secret 时生成一个，只在这里返回明文
+func (s *WebhookService) Create(req *request.Webhook, result *response.Webhook) (int, error) {
+	if req.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return statecode.CommonErrServerErr, err
+		}
+		req.Secret = hex.EncodeToString(b)
+	}
+	w := models.NewWebhook()
+	if err := w.Create(req); err != nil {
+		return statecode.CommonErrServerErr, err
+	}
+	*result = webhookResponse(w)
+	result.Secret = w.Secret
+	return statecode.CommonSuccess, nil
+}
+
+func (s *WebhookService) Update(req *request.WebhookUpdate) (int, error) {
+	err := models.NewWebhook().Update(req)
+	if errors.Is(err, models.ErrWebhookNotExist) {
+		return statecode.WebhookNotExist, err
+	}
+	if err != nil {
+		return statecode.CommonErrServerErr, err
+	}
+	return statecode.CommonSuccess, nil
+}
+
+func (s *WebhookService) Delete(id int64) (int, error) {
+	err := models.NewWebhook().Delete(id)
+	if errors.Is(err, models.ErrWebhookNotExist) {
+		return statecode.WebhookNotExist, err
+	}
+	if err != nil {
+		return statecode.CommonErrServerErr, err
+	}
+	return statecode.CommonSuccess, nil
+}
+
+func (s *WebhookService) List(result *[]response.Webhook) (int, error) {
+	list, err := models.NewWebhook().List()
+	if err != nil {
+		return statecode.CommonErrServerErr, err
+	}
+	*result = make([]response.Webhook, 0, len(list))
+	for _, w := range list {
+		*result = append(*result, webhookResponse(&w))
+	}
+	return statecode.CommonSuccess, nil
+}
+
+// Deliveries 投递记录
+func (s *WebhookService) Deliveries(req *request.WebhookDeliveries) (int, int64, []response.WebhookDelivery, error) {
+	err, total, list := models.NewWebhook().Deliveries(req)
+	if err != nil {
+		return statecode.CommonErrServerErr, 0, nil, err
+	}
+	result := make([]response.WebhookDelivery, 0, len(list))
+	for _, d := range list {
+		result = append(result, response.WebhookDelivery{
+			Id:           d.Id,
+			WebhookId:    d.WebhookId,
+			ChainId:      d.ChainId,
+			TxHash:       d.TxHash,
+			LogIndex:     d.LogIndex,
+			Event:        d.Event,
+			Payload:      d.Payload,
+			Removed:      d.Removed,
+			Status:       d.Status,
+			Attempts:     d.Attempts,
+			ResponseCode: d.ResponseCode,
+			Error:        d.Error,
+			NextRetryAt:  d.NextRetryAt.Unix(),
+			CreatedAt:    d.CreatedAt.Unix(),
+			UpdatedAt:    d.UpdatedAt.Unix(),
+		})
+	}
+	return statecode.CommonSuccess, total, result, nil
+}
+
+// 列表里的密钥只保留前 4 位
+func webhookResponse(w *models.Webhook) response.Webhook {
+	secret := w.Secret
+	if len(secret) > 4 {
+		secret = secret[:4] + "****"
+	}
+	return response.Webhook{
+		Id:             w.Id,
+		Url:            w.Url,
+		Secret:         secret,
+		ChainId:        w.ChainId,
+		Events:         nonNil(w.Events),
+		PoolIds:        nonNil(w.PoolIds),
+		Addresses:      nonNil(w.Addresses),
+		Enabled:        w.Enabled,
+		Failures:       w.Failures,
+		DisabledReason: w.DisabledReason,
+		CreatedAt:      w.CreatedAt.Unix(),
+	}
+}
+
+// 空过滤条件返回 [] 而不是 null
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
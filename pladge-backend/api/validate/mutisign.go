@@ -56,3 +56,27 @@ func (v *MutiSign) GetMultiSign(c *gin.Context, req *request.GetMultiSign) int {
 
 	return statecode.CommonSuccess
 }
+
+func (v *MutiSign) GetApplications(c *gin.Context, req *request.MultiSignApplications) int {
+
+	err := c.ShouldBind(req)
+	if err == io.EOF {
+		return statecode.ParameterEmptyErr
+	} else if err != nil {
+		return statecode.CommonErrServerErr
+	}
+	if req.ChainId != 97 && req.ChainId != 56 {
+		return statecode.ChainIdErr
+	}
+	if req.Status != "" && req.Status != "pending" && req.Status != "approved" && req.Status != "revoked" {
+		return statecode.MultiSignStatusErr
+	}
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 || req.PageSize > 100 {
+		req.PageSize = 20
+	}
+
+	return statecode.CommonSuccess
+}
@@ -0,0 +1,82 @@
+package validate
+
+import (
+	"pledge-backend/api/common/statecode"
+	"pledge-backend/api/models/request"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type PoolDetail struct{}
+
+func NewPoolDetail() *PoolDetail {
+	return &PoolDetail{}
+}
+
+// PoolDetail 校验路径里的 chainId 和 poolId
+func (v *PoolDetail) PoolDetail(c *gin.Context, req *request.PoolDetail) int {
+
+	err := c.ShouldBindUri(req)
+	if err != nil {
+		// 不是数字时绑定失败，不是 ValidationErrors
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return statecode.CommonErrServerErr
+		}
+		for _, e := range errs {
+			if e.Field() == "ChainId" {
+				return statecode.ChainIdEmpty
+			}
+		}
+		return statecode.PoolNotExist
+	}
+	if req.ChainId != 97 && req.ChainId != 56 {
+		return statecode.ChainIdErr
+	}
+	if req.PoolId <= 0 {
+		return statecode.PoolNotExist
+	}
+
+	return statecode.CommonSuccess
+}
+
+// 一次最多返回的点数
+const maxHistoryPoints = 2000
+
+// PoolHistory 默认 interval=hour；to 默认当前时间，from 默认小时粒度往前 7 天、天粒度往前 365 天
+func (v *PoolDetail) PoolHistory(c *gin.Context, req *request.PoolHistory) int {
+
+	if errCode := v.PoolDetail(c, &req.PoolDetail); errCode != statecode.CommonSuccess {
+		return errCode
+	}
+	if err := c.ShouldBindQuery(req); err != nil {
+		return statecode.PoolHistoryErr
+	}
+
+	var step int64
+	switch req.Interval {
+	case "", "hour":
+		req.Interval, step = "hour", 3600
+	case "day":
+		step = 86400
+	default:
+		return statecode.PoolHistoryErr
+	}
+	if req.To <= 0 {
+		req.To = time.Now().Unix()
+	}
+	if req.From <= 0 {
+		if step == 3600 {
+			req.From = req.To - 7*86400
+		} else {
+			req.From = req.To - 365*86400
+		}
+	}
+	if req.From > req.To || (req.To-req.From)/step > maxHistoryPoints {
+		return statecode.PoolHistoryErr
+	}
+
+	return statecode.CommonSuccess
+}
@@ -0,0 +1,48 @@
+package validate
+
+import (
+	"io"
+	"pledge-backend/api/common/statecode"
+	"pledge-backend/api/models/request"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type Position struct{}
+
+func NewPosition() *Position {
+	return &Position{}
+}
+
+// UserPositions 校验钱包地址和 chainId
+func (v *Position) UserPositions(c *gin.Context, req *request.UserPositions) int {
+
+	err := c.ShouldBind(req)
+	if err == io.EOF {
+		return statecode.ParameterEmptyErr
+	} else if err != nil {
+		errs, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return statecode.ChainIdErr
+		}
+		for _, e := range errs {
+			if e.Field() == "ChainId" && e.Tag() == "required" {
+				return statecode.ChainIdEmpty
+			}
+		}
+		return statecode.CommonErrServerErr
+	}
+	if req.ChainId != 97 && req.ChainId != 56 {
+		return statecode.ChainIdErr
+	}
+
+	req.Address = c.Param("address")
+	if !common.IsHexAddress(req.Address) {
+		return statecode.AddressErr
+	}
+	req.Address = common.HexToAddress(req.Address).Hex()
+
+	return statecode.CommonSuccess
+}
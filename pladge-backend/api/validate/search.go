@@ -3,6 +3,7 @@ package validate
 import (
 	"io"
 	"pledge-backend/api/common/statecode"
+	"pledge-backend/api/models"
 	"pledge-backend/api/models/request"
 
 	"github.com/gin-gonic/gin"
@@ -42,6 +43,41 @@ func (s *Search) Search(c *gin.Context, req *request.Search) int {
 	if req.ChainID != 97 && req.ChainID != 56 {
 		return statecode.ChainIdErr
 	}
-	// 5. 校验通过，返回成功代码
+	// 5. 状态：旧参数 state 合并进 states，只能是合约里的 0~4
+	if req.State != "" {
+		req.States = append(req.States, req.State)
+	}
+	for _, st := range req.States {
+		if len(st) != 1 || st[0] < '0' || st[0] > '4' {
+			return statecode.PoolSearchErr
+		}
+	}
+	// 6. 排序字段白名单，默认按 pool_id 倒序
+	if req.SortBy == "" {
+		req.SortBy = "pool_id"
+	}
+	if !models.SearchSortable(req.SortBy) {
+		return statecode.PoolSearchErr
+	}
+	if req.Order == "" {
+		req.Order = "desc"
+	}
+	if req.Order != "asc" && req.Order != "desc" {
+		return statecode.PoolSearchErr
+	}
+	// 7. 区间不能为负，每页默认 10 条、最多 100 条
+	for _, n := range []int64{req.InterestRateMin, req.InterestRateMax, req.MartgageRateMin, req.MartgageRateMax,
+		req.SettleTimeFrom, req.SettleTimeTo, req.EndTimeFrom, req.EndTimeTo} {
+		if n < 0 {
+			return statecode.PoolSearchErr
+		}
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 10
+	}
+	if req.PageSize > 100 {
+		req.PageSize = 100
+	}
+	// 8. 校验通过，返回成功代码
 	return statecode.CommonSuccess
 }
@@ -0,0 +1,148 @@
+package validate
+
+import (
+	"io"
+	"net/url"
+	"pledge-backend/api/common/statecode"
+	"pledge-backend/api/models/request"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+)
+
+type Webhook struct{}
+
+func NewWebhook() *Webhook {
+	return &Webhook{}
+}
+
+// Save 新建
+func (v *Webhook) Save(c *gin.Context, req *request.Webhook) int {
+
+	err := c.ShouldBind(req)
+	if err == io.EOF {
+		return statecode.ParameterEmptyErr
+	} else if err != nil {
+		return statecode.CommonErrServerErr
+	}
+
+	for _, code := range []int{
+		webhookUrl(req.Url),
+		webhookChainId(req.ChainId),
+		webhookAddresses(req.Addresses),
+		webhookPoolIds(req.PoolIds),
+	} {
+		if code != statecode.CommonSuccess {
+			return code
+		}
+	}
+
+	return statecode.CommonSuccess
+}
+
+// Update 修改，只校验传了的字段
+func (v *Webhook) Update(c *gin.Context, req *request.WebhookUpdate) int {
+
+	err := c.ShouldBind(req)
+	if err == io.EOF {
+		return statecode.ParameterEmptyErr
+	} else if err != nil {
+		return statecode.CommonErrServerErr
+	}
+	if req.Id <= 0 {
+		return statecode.WebhookNotExist
+	}
+
+	if req.Url != nil {
+		if code := webhookUrl(*req.Url); code != statecode.CommonSuccess {
+			return code
+		}
+	}
+	if req.ChainId != nil {
+		if code := webhookChainId(*req.ChainId); code != statecode.CommonSuccess {
+			return code
+		}
+	}
+	if req.Addresses != nil {
+		if code := webhookAddresses(*req.Addresses); code != statecode.CommonSuccess {
+			return code
+		}
+	}
+	if req.PoolIds != nil {
+		return webhookPoolIds(*req.PoolIds)
+	}
+
+	return statecode.CommonSuccess
+}
+
+func webhookUrl(rawUrl string) int {
+	u, err := url.Parse(rawUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return statecode.WebhookUrlErr
+	}
+	return statecode.CommonSuccess
+}
+
+func webhookChainId(chainId int) int {
+	if chainId != 0 && chainId != 97 && chainId != 56 {
+		return statecode.ChainIdErr
+	}
+	return statecode.CommonSuccess
+}
+
+// 地址统一转小写
+func webhookAddresses(addresses []string) int {
+	for i, a := range addresses {
+		if !common.IsHexAddress(a) {
+			return statecode.WebhookAddressErr
+		}
+		addresses[i] = strings.ToLower(a)
+	}
+	return statecode.CommonSuccess
+}
+
+func webhookPoolIds(poolIds []int64) int {
+	for _, pid := range poolIds {
+		if pid < 0 {
+			return statecode.WebhookPoolIdErr
+		}
+	}
+	return statecode.CommonSuccess
+}
+
+func (v *Webhook) Id(c *gin.Context, req *request.WebhookId) int {
+
+	err := c.ShouldBind(req)
+	if err == io.EOF {
+		return statecode.ParameterEmptyErr
+	} else if err != nil {
+		return statecode.CommonErrServerErr
+	}
+	if req.Id <= 0 {
+		return statecode.WebhookNotExist
+	}
+
+	return statecode.CommonSuccess
+}
+
+func (v *Webhook) Deliveries(c *gin.Context, req *request.WebhookDeliveries) int {
+
+	err := c.ShouldBind(req)
+	if err == io.EOF {
+		return statecode.ParameterEmptyErr
+	} else if err != nil {
+		return statecode.CommonErrServerErr
+	}
+	if req.Status != "" && req.Status != "pending" && req.Status != "delivered" && req.Status != "failed" {
+		return statecode.WebhookStatusErr
+	}
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 || req.PageSize > 100 {
+		req.PageSize = 20
+	}
+
+	return statecode.CommonSuccess
+}
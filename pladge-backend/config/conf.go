@@ -21,6 +21,24 @@ type Conf struct {
 	Jwt JwtConfig
 	// (运行环境): 定义了 API 端口、版本以及关键的超时时间（如 TaskDuration 任务持续时间、WssTimeoutDuration WebSocket 超时）
 	Env EnvConfig
+	// contract-listener：Redis 通知频道、链上索引数据库
+	Listener ListenerConfig
+	// 池子历史快照的频率和保留时间
+	Snapshot SnapshotConfig
+}
+
+type ListenerConfig struct {
+	PoolRefreshChannel string `toml:"pool_refresh_channel"`
+	GovernanceChannel  string `toml:"governance_channel"`
+	PositionsPrefix    string `toml:"positions_prefix"`
+	Dsn                string `toml:"dsn"`
+	FullSyncMinutes    uint64 `toml:"full_sync_minutes"`
+}
+
+type SnapshotConfig struct {
+	IntervalMinutes     uint64 `toml:"interval_minutes"`
+	HourlyRetentionDays int    `toml:"hourly_retention_days"`
+	DailyRetentionDays  int    `toml:"daily_retention_days"`
 }
 
 type EnvConfig struct {
@@ -1,9 +1,14 @@
 package db
 
 import (
+	"database/sql"
+
 	"github.com/gomodule/redigo/redis"
 	"gorm.io/gorm"
 )
 
 var Mysql *gorm.DB
 var RedisConn *redis.Pool
+
+// contract-listener 的 Postgres，只读
+var Listener *sql.DB
@@ -0,0 +1,28 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"pledge-backend/config"
+	"pledge-backend/log"
+
+	_ "github.com/lib/pq"
+)
+
+// InitListenerDB 连接 contract-listener 的 Postgres，未配置 dsn 时跳过，相关接口返回错误
+func InitListenerDB() {
+	dsn := config.Config.Listener.Dsn
+	if dsn == "" {
+		log.Logger.Info("listener dsn not configured")
+		return
+	}
+	log.Logger.Info("Init Listener DB")
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		log.Logger.Panic(fmt.Sprintf("listener db connention error ==>  %+v", err))
+	}
+	if err = conn.Ping(); err != nil {
+		log.Logger.Panic(fmt.Sprintf("listener db ping error ==>  %+v", err))
+	}
+	Listener = conn
+}
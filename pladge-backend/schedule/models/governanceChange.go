@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"pledge-backend/db"
+	"time"
+)
+
+// GovernanceChange contract-listener 记录的关键协议参数变更，alerted_at 为空表示还没发出告警
+type GovernanceChange struct {
+	ChainId   int64
+	Contract  string
+	Param     string
+	Old       string
+	New       string
+	TxHash    string
+	LogIndex  int
+	Block     uint64
+	BlockTime time.Time
+	Sender    string
+}
+
+// 每次最多处理的条数
+const governanceAlertBatch = 50
+
+func NewGovernanceChange() *GovernanceChange {
+	return &GovernanceChange{}
+}
+
+// AlertPending 对还没告警的关键变更逐条调用 send，成功后才填 alerted_at；send 失败时停下，剩下的下次再发
+// 行锁（SKIP LOCKED）保证多个 schedule 实例不会重复发送
+func (g *GovernanceChange) AlertPending(send func(GovernanceChange) error) (int, error) {
+	if db.Listener == nil {
+		return 0, errors.New("listener db not configured")
+	}
+
+	tx, err := db.Listener.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	rows, err := tx.Query(
+		`SELECT chain_id, contract, param, old_value, new_value, tx_hash, log_index, block_number, block_time, sender
+		FROM governance_change
+		WHERE critical AND alerted_at IS NULL
+		ORDER BY block_number, log_index
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`,
+		governanceAlertBatch,
+	)
+	if err != nil {
+		return 0, errors.New("record select err " + err.Error())
+	}
+
+	var pending []GovernanceChange
+	for rows.Next() {
+		var c GovernanceChange
+		err = rows.Scan(&c.ChainId, &c.Contract, &c.Param, &c.Old, &c.New, &c.TxHash, &c.LogIndex, &c.Block, &c.BlockTime, &c.Sender)
+		if err != nil {
+			_ = rows.Close()
+			return 0, errors.New("record select err " + err.Error())
+		}
+		pending = append(pending, c)
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, errors.New("record select err " + err.Error())
+	}
+
+	sent := 0
+	var sendErr error
+	for _, c := range pending {
+		if sendErr = send(c); sendErr != nil {
+			break
+		}
+		_, err = tx.Exec(
+			`UPDATE governance_change SET alerted_at = now()
+			WHERE chain_id = $1 AND tx_hash = $2 AND log_index = $3 AND param = $4`,
+			c.ChainId, c.TxHash, c.LogIndex, c.Param,
+		)
+		if err != nil {
+			return 0, errors.New("record update err " + err.Error())
+		}
+		sent++
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return sent, sendErr
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"pledge-backend/db"
+
+	"gorm.io/gorm/clause"
+)
+
+// 快照粒度
+const (
+	SnapshotHour = "hour"
+	SnapshotDay  = "day"
+)
+
+// PoolSnapshot 池子在一个时间桶里最后一次采样的值，poolbases / pooldata 只保留当前值，走势图从这里取
+type PoolSnapshot struct {
+	Id                      int64  `json:"-" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId                 string `json:"chain_id" gorm:"column:chain_id;type:varchar(20);uniqueIndex:uk_pool_snapshot,priority:1"`
+	PoolId                  int    `json:"pool_id" gorm:"column:pool_id;uniqueIndex:uk_pool_snapshot,priority:2"`
+	Granularity             string `json:"granularity" gorm:"column:granularity;type:varchar(8);uniqueIndex:uk_pool_snapshot,priority:3;index:idx_pool_snapshot_prune,priority:1"`
+	Bucket                  int64  `json:"bucket" gorm:"column:bucket;uniqueIndex:uk_pool_snapshot,priority:4;index:idx_pool_snapshot_prune,priority:2"` // 桶起始时间（UTC 整点 / 零点），unix 秒
+	State                   string `json:"state" gorm:"column:state;type:varchar(100)"`
+	MaxSupply               string `json:"max_supply" gorm:"column:max_supply;type:varchar(100)"`
+	LendSupply              string `json:"lend_supply" gorm:"column:lend_supply;type:varchar(100)"`
+	BorrowSupply            string `json:"borrow_supply" gorm:"column:borrow_supply;type:varchar(100)"`
+	SettleAmountLend        string `json:"settle_amount_lend" gorm:"column:settle_amount_lend;type:varchar(100)"`
+	SettleAmountBorrow      string `json:"settle_amount_borrow" gorm:"column:settle_amount_borrow;type:varchar(100)"`
+	FinishAmountLend        string `json:"finish_amount_lend" gorm:"column:finish_amount_lend;type:varchar(100)"`
+	FinishAmountBorrow      string `json:"finish_amount_borrow" gorm:"column:finish_amount_borrow;type:varchar(100)"`
+	LiquidationAmountLend   string `json:"liquidation_amount_lend" gorm:"column:liquidation_amount_lend;type:varchar(100)"`
+	LiquidationAmountBorrow string `json:"liquidation_amount_borrow" gorm:"column:liquidation_amount_borrow;type:varchar(100)"`
+	LendTokenPrice          string `json:"lend_token_price" gorm:"column:lend_token_price;type:varchar(100)"`
+	BorrowTokenPrice        string `json:"borrow_token_price" gorm:"column:borrow_token_price;type:varchar(100)"`
+	SampledAt               int64  `json:"sampled_at" gorm:"column:sampled_at"` // 最后一次采样时间，unix 秒
+}
+
+func NewPoolSnapshot() *PoolSnapshot {
+	return &PoolSnapshot{}
+}
+
+func (s *PoolSnapshot) TableName() string {
+	return "pool_snapshot"
+}
+
+// SaveSnapshots 写入快照，同一个桶已有的用这次采样覆盖
+func (s *PoolSnapshot) SaveSnapshots(snapshots []PoolSnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+	return db.Mysql.Table("pool_snapshot").Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{
+			"state", "max_supply", "lend_supply", "borrow_supply",
+			"settle_amount_lend", "settle_amount_borrow", "finish_amount_lend", "finish_amount_borrow",
+			"liquidation_amount_lend", "liquidation_amount_borrow",
+			"lend_token_price", "borrow_token_price", "sampled_at",
+		}),
+	}).CreateInBatches(&snapshots, 200).Error
+}
+
+// DeleteSnapshotsBefore 删除 bucket 早于 before 的快照
+func (s *PoolSnapshot) DeleteSnapshotsBefore(granularity string, before int64) (int64, error) {
+	res := db.Mysql.Table("pool_snapshot").Where("granularity = ? AND bucket < ?", granularity, before).Delete(&PoolSnapshot{})
+	return res.RowsAffected, res.Error
+}
+
+// History 一个池子 [from, to] 内的快照，按时间升序
+func (s *PoolSnapshot) History(chainId string, poolId int, granularity string, from, to int64) ([]PoolSnapshot, error) {
+	snapshots := make([]PoolSnapshot, 0)
+	err := db.Mysql.Table("pool_snapshot").
+		Where("chain_id = ? AND pool_id = ? AND granularity = ? AND bucket >= ? AND bucket <= ?", chainId, poolId, granularity, from, to).
+		Order("bucket asc").Find(&snapshots).Error
+	return snapshots, err
+}
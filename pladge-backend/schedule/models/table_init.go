@@ -8,4 +8,5 @@ func InitTable() {
 	db.Mysql.AutoMigrate(&PoolData{})
 	db.Mysql.AutoMigrate(&RedisTokenInfo{})
 	db.Mysql.AutoMigrate(&TokenInfo{})
+	db.Mysql.AutoMigrate(&PoolSnapshot{})
 }
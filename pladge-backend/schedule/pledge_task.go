@@ -14,6 +14,9 @@ func main() {
 	// init redis
 	db.InitRedis()
 
+	// init contract-listener db (governance alerts)
+	db.InitListenerDB()
+
 	// create table
 	models.InitTable()
 
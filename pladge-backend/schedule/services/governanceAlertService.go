@@ -0,0 +1,150 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"pledge-backend/config"
+	"pledge-backend/db"
+	"pledge-backend/log"
+	"pledge-backend/schedule/models"
+	"pledge-backend/utils"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// GovernanceMsg contract-listener 发布的关键协议参数变更
+type GovernanceMsg struct {
+	ChainId   string `json:"chain_id"`
+	Contract  string `json:"contract"`
+	Param     string `json:"param"`
+	Old       string `json:"old"`
+	New       string `json:"new"`
+	Sender    string `json:"sender"`
+	Block     uint64 `json:"block"`
+	BlockTime int64  `json:"block_time"`
+	TxHash    string `json:"tx_hash"`
+}
+
+// 订阅 contract-listener 的治理变更通知，关键参数（费率、路由、手续费地址、暂停、owner）变化时发告警邮件
+// 配了 listener 库时以 governance_change.alerted_at 为准：收到通知或定时扫描，邮件发出后才标记，
+// 通知丢失、schedule 不在线、邮件发送失败都会在下一次扫描补发
+type governanceAlertService struct{}
+
+func NewGovernanceAlert() *governanceAlertService {
+	return &governanceAlertService{}
+}
+
+// 收到通知后触发一次扫描，扫描进行中再来的通知合并成一次
+var governanceSweep = make(chan struct{}, 1)
+
+// Sweep 发送还没告警的关键变更
+func (s *governanceAlertService) Sweep() {
+	if db.Listener == nil {
+		return
+	}
+	sent, err := models.NewGovernanceChange().AlertPending(func(c models.GovernanceChange) error {
+		return s.alert(GovernanceMsg{
+			ChainId:   strconv.FormatInt(c.ChainId, 10),
+			Contract:  c.Contract,
+			Param:     c.Param,
+			Old:       c.Old,
+			New:       c.New,
+			Sender:    c.Sender,
+			Block:     c.Block,
+			BlockTime: c.BlockTime.Unix(),
+			TxHash:    c.TxHash,
+		})
+	})
+	if sent > 0 {
+		log.Logger.Sugar().Info("governance alerts sent ", sent)
+	}
+	if err != nil {
+		log.Logger.Sugar().Error("governance alert err ", err)
+	}
+}
+
+// Subscribe 阻塞订阅，断线后自动重连
+func (s *governanceAlertService) Subscribe() {
+
+	channel := config.Config.Listener.GovernanceChannel
+	if channel == "" {
+		log.Logger.Info("governance channel not configured")
+		return
+	}
+
+	go func() {
+		for range governanceSweep {
+			s.Sweep()
+		}
+	}()
+
+	for {
+		err := s.receive(channel)
+		log.Logger.Sugar().Error("governance subscribe err ", err)
+		time.Sleep(3 * time.Second)
+	}
+}
+
+func (s *governanceAlertService) receive(channel string) error {
+
+	conn := db.RedisConn.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	psc := redis.PubSubConn{Conn: conn}
+	if err := psc.Subscribe(channel); err != nil {
+		return err
+	}
+	log.Logger.Sugar().Info("governance subscribed ", channel)
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			var msg GovernanceMsg
+			if err := json.Unmarshal(v.Data, &msg); err != nil {
+				log.Logger.Sugar().Error("governance bad message ", string(v.Data), err)
+				continue
+			}
+			// 没有 listener 库时只能按通知直接发
+			if db.Listener == nil {
+				go func() {
+					_ = s.alert(msg)
+				}()
+				continue
+			}
+			// 发邮件较慢，不阻塞接收
+			select {
+			case governanceSweep <- struct{}{}:
+			default:
+			}
+		case error:
+			return v
+		}
+	}
+}
+
+func (s *governanceAlertService) alert(msg GovernanceMsg) error {
+
+	log.Logger.Sugar().Info("governance change ", msg.ChainId, " ", msg.Contract, " ", msg.Param, " ", msg.Old, " -> ", msg.New, " ", msg.TxHash)
+
+	err := utils.SendEmail(s.EmailBody(msg), 2)
+	if err != nil {
+		log.Logger.Error(err.Error())
+	}
+	return err
+}
+
+func (s *governanceAlertService) EmailBody(msg GovernanceMsg) []byte {
+	blockTime := time.Unix(msg.BlockTime, 0).UTC().Format("2006-01-02 15:04:05")
+	body := fmt.Sprintf(`<p>&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;Protocol parameter <strong><span style="color: rgb(255, 0, 0);"> %s </span></strong> of contract <strong>%s</strong> on chain %s changed from <strong>%s</strong> to <strong>%s</strong>.
+</p>
+<p>&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;Sender: %s, block: %d (%s UTC), tx: %s. Please confirm the change is expected.
+</p>`, html.EscapeString(msg.Param), html.EscapeString(msg.Contract), html.EscapeString(msg.ChainId),
+		html.EscapeString(msg.Old), html.EscapeString(msg.New),
+		html.EscapeString(msg.Sender), msg.Block, blockTime, html.EscapeString(msg.TxHash))
+	return []byte(body)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"encoding/json"
+	"pledge-backend/config"
+	"pledge-backend/db"
+	"pledge-backend/log"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// 同一批通知合并的时间窗口：同一交易里的多个事件、同一池子的连续操作只刷新一次
+const poolRefreshDebounce = time.Second
+
+// PoolRefreshMsg contract-listener 发布的池子刷新通知
+type PoolRefreshMsg struct {
+	ChainId string `json:"chain_id"`
+	PoolId  int64  `json:"pool_id"` // 合约里的 pid，从 0 开始
+	Event   string `json:"event"`
+	Block   uint64 `json:"block"`
+	TxHash  string `json:"tx_hash"`
+}
+
+type poolKey struct {
+	chainId string
+	pid     int64
+}
+
+// 订阅 contract-listener 的事件通知，只刷新发生变化的池子；定时全量同步仍然保留兜底
+type poolRefreshService struct{}
+
+func NewPoolRefresh() *poolRefreshService {
+	return &poolRefreshService{}
+}
+
+// Subscribe 阻塞订阅，断线后自动重连
+func (s *poolRefreshService) Subscribe() {
+
+	channel := config.Config.Listener.PoolRefreshChannel
+	if channel == "" {
+		log.Logger.Info("pool refresh channel not configured")
+		return
+	}
+
+	msgs := make(chan PoolRefreshMsg, 1000)
+	go s.refreshLoop(msgs)
+
+	for {
+		err := s.receive(channel, msgs)
+		log.Logger.Sugar().Error("pool refresh subscribe err ", err)
+		time.Sleep(3 * time.Second)
+	}
+}
+
+func (s *poolRefreshService) receive(channel string, msgs chan<- PoolRefreshMsg) error {
+
+	conn := db.RedisConn.Get()
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	psc := redis.PubSubConn{Conn: conn}
+	if err := psc.Subscribe(channel); err != nil {
+		return err
+	}
+	log.Logger.Sugar().Info("pool refresh subscribed ", channel)
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			var msg PoolRefreshMsg
+			if err := json.Unmarshal(v.Data, &msg); err != nil {
+				log.Logger.Sugar().Error("pool refresh bad message ", string(v.Data), err)
+				continue
+			}
+			msgs <- msg
+		case error:
+			return v
+		}
+	}
+}
+
+// 合并窗口内的通知，逐个池子刷新
+func (s *poolRefreshService) refreshLoop(msgs <-chan PoolRefreshMsg) {
+
+	pending := make(map[poolKey]PoolRefreshMsg)
+	timer := time.NewTimer(poolRefreshDebounce)
+	timer.Stop()
+
+	for {
+		select {
+		case msg := <-msgs:
+			if len(pending) == 0 {
+				timer.Reset(poolRefreshDebounce)
+			}
+			pending[poolKey{msg.ChainId, msg.PoolId}] = msg
+
+		case <-timer.C:
+			for k, msg := range pending {
+				s.refresh(msg)
+				delete(pending, k)
+			}
+		}
+	}
+}
+
+func (s *poolRefreshService) refresh(msg PoolRefreshMsg) {
+
+	var contractAddress, network string
+	switch msg.ChainId {
+	case config.Config.TestNet.ChainId:
+		contractAddress, network = config.Config.TestNet.PledgePoolToken, config.Config.TestNet.NetUrl
+	case config.Config.MainNet.ChainId:
+		contractAddress, network = config.Config.MainNet.PledgePoolToken, config.Config.MainNet.NetUrl
+	default:
+		log.Logger.Sugar().Error("pool refresh unknown chain ", msg.ChainId)
+		return
+	}
+
+	err := NewPool().RefreshPool(contractAddress, network, msg.ChainId, msg.PoolId)
+	if err != nil {
+		log.Logger.Sugar().Error("pool refresh err ", msg.ChainId, " ", msg.PoolId, " ", msg.Event, " ", msg.TxHash, " ", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"pledge-backend/schedule/models"
 	"pledge-backend/utils"
 	"strings"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
@@ -30,26 +31,29 @@ func (s *poolService) UpdateAllPoolInfo() {
 
 }
 
+// FullSyncInterval 全量同步的间隔（分钟），平时靠 listener 通知单个池子刷新
+func (s *poolService) FullSyncInterval() uint64 {
+	if config.Config.Listener.FullSyncMinutes == 0 {
+		return 30
+	}
+	return config.Config.Listener.FullSyncMinutes
+}
+
 func (s *poolService) UpdatePoolInfo(contractAddress, network, chainId string) {
 
 	log.Logger.Sugar().Info("UpdatePoolInfo ", contractAddress+" "+network)
-	ethereumConn, err := ethclient.Dial(network)
+	pledgePoolToken, err := s.poolToken(contractAddress, network)
 	if nil != err {
 		log.Logger.Error(err.Error())
 		return
 	}
-	pledgePoolToken, err := bindings.NewPledgePoolToken(common.HexToAddress(contractAddress), ethereumConn)
+
+	lendFee, borrowFee, err := s.poolFees(pledgePoolToken)
 	if nil != err {
 		log.Logger.Error(err.Error())
 		return
 	}
 
-	// borrowFee
-	borrowFee, err := pledgePoolToken.PledgePoolTokenCaller.BorrowFee(nil)
-
-	// lendFee
-	lendFee, err := pledgePoolToken.PledgePoolTokenCaller.LendFee(nil)
-
 	//poolLength
 	pLength, err := pledgePoolToken.PledgePoolTokenCaller.PoolLength(nil)
 	if nil != err {
@@ -58,91 +62,151 @@ func (s *poolService) UpdatePoolInfo(contractAddress, network, chainId string) {
 	}
 
 	for i := 0; i <= int(pLength.Int64())-1; i++ {
-
 		log.Logger.Sugar().Info("UpdatePoolInfo ", i)
-		poolId := utils.IntToString(i + 1)
-		baseInfo, err := pledgePoolToken.PledgePoolTokenCaller.PoolBaseInfo(nil, big.NewInt(int64(i)))
-		if err != nil {
-			log.Logger.Sugar().Info("UpdatePoolInfo PoolBaseInfo err", poolId, err)
-			continue
-		}
+		_ = s.updatePool(pledgePoolToken, chainId, int64(i), lendFee, borrowFee)
+	}
+}
 
-		_, borrowToken := models.NewTokenInfo().GetTokenInfo(baseInfo.BorrowToken.String(), chainId)
-		_, lendToken := models.NewTokenInfo().GetTokenInfo(baseInfo.LendToken.String(), chainId)
-
-		lendTokenJson, _ := json.Marshal(models.LendToken{
-			LendFee:    lendFee.String(),
-			TokenLogo:  lendToken.Logo,
-			TokenName:  lendToken.Symbol,
-			TokenPrice: lendToken.Price,
-		})
-		borrowTokenJson, _ := json.Marshal(models.BorrowToken{
-			BorrowFee:  borrowFee.String(),
-			TokenLogo:  borrowToken.Logo,
-			TokenName:  borrowToken.Symbol,
-			TokenPrice: borrowToken.Price,
-		})
-
-		poolBase := models.PoolBase{
-			SettleTime:             baseInfo.SettleTime.String(),
-			PoolId:                 utils.StringToInt(poolId),
-			ChainId:                chainId,
-			EndTime:                baseInfo.EndTime.String(),
-			InterestRate:           baseInfo.InterestRate.String(),
-			MaxSupply:              baseInfo.MaxSupply.String(),
-			LendSupply:             baseInfo.LendSupply.String(),
-			BorrowSupply:           baseInfo.BorrowSupply.String(),
-			MartgageRate:           baseInfo.MartgageRate.String(),
-			LendToken:              baseInfo.LendToken.String(),
-			LendTokenSymbol:        lendToken.Symbol,
-			LendTokenInfo:          string(lendTokenJson),
-			BorrowToken:            baseInfo.BorrowToken.String(),
-			BorrowTokenSymbol:      borrowToken.Symbol,
-			BorrowTokenInfo:        string(borrowTokenJson),
-			State:                  utils.IntToString(int(baseInfo.State)),
-			SpCoin:                 baseInfo.SpCoin.String(),
-			JpCoin:                 baseInfo.JpCoin.String(),
-			AutoLiquidateThreshold: baseInfo.AutoLiquidateThreshold.String(),
-		}
+// RefreshPool 只刷新一个池子，由 contract-listener 的事件通知触发
+// pid 为合约里的池子下标（从 0 开始），库里的 pool_id 为 pid+1
+func (s *poolService) RefreshPool(contractAddress, network, chainId string, pid int64) error {
+
+	log.Logger.Sugar().Info("RefreshPool ", chainId, " ", pid)
+	pledgePoolToken, err := s.poolToken(contractAddress, network)
+	if nil != err {
+		return err
+	}
+
+	lendFee, borrowFee, err := s.poolFees(pledgePoolToken)
+	if nil != err {
+		return err
+	}
+
+	return s.updatePool(pledgePoolToken, chainId, pid, lendFee, borrowFee)
+}
+
+func (s *poolService) poolFees(pledgePoolToken *bindings.PledgePoolToken) (*big.Int, *big.Int, error) {
+
+	// lendFee
+	lendFee, err := pledgePoolToken.PledgePoolTokenCaller.LendFee(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// borrowFee
+	borrowFee, err := pledgePoolToken.PledgePoolTokenCaller.BorrowFee(nil)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		hasInfoData, byteBaseInfoStr, baseInfoMd5Str := s.GetPoolMd5(&poolBase, "base_info:pool_"+chainId+"_"+poolId)
-		if !hasInfoData || (baseInfoMd5Str != byteBaseInfoStr) { // have new data
-			//tokenInfo
-			err = models.NewPoolBase().SavePoolBase(chainId, poolId, &poolBase)
-			if err != nil {
-				log.Logger.Sugar().Error("SavePoolBase err ", chainId, poolId)
-			}
-			_ = db.RedisSet("base_info:pool_"+chainId+"_"+poolId, baseInfoMd5Str, 60*30) //The expiration time is set to prevent hsah collision
+	return lendFee, borrowFee, nil
+}
+
+// 节点连接按 network 复用，事件触发的刷新不用每次重新建连
+var poolClients sync.Map
+
+func (s *poolService) poolToken(contractAddress, network string) (*bindings.PledgePoolToken, error) {
+
+	conn, ok := poolClients.Load(network)
+	if !ok {
+		c, err := ethclient.Dial(network)
+		if nil != err {
+			return nil, err
 		}
+		conn, _ = poolClients.LoadOrStore(network, c)
+	}
+
+	return bindings.NewPledgePoolToken(common.HexToAddress(contractAddress), conn.(*ethclient.Client))
+}
+
+// 同步一个池子的 PoolBaseInfo / PoolDataInfo，内容没变（md5 相同）不写库
+func (s *poolService) updatePool(pledgePoolToken *bindings.PledgePoolToken, chainId string, pid int64, lendFee, borrowFee *big.Int) error {
+
+	poolId := utils.IntToString(int(pid) + 1)
+	baseInfo, err := pledgePoolToken.PledgePoolTokenCaller.PoolBaseInfo(nil, big.NewInt(pid))
+	if err != nil {
+		log.Logger.Sugar().Info("UpdatePoolInfo PoolBaseInfo err", poolId, err)
+		return err
+	}
 
-		dataInfo, err := pledgePoolToken.PledgePoolTokenCaller.PoolDataInfo(nil, big.NewInt(int64(i)))
+	_, borrowToken := models.NewTokenInfo().GetTokenInfo(baseInfo.BorrowToken.String(), chainId)
+	_, lendToken := models.NewTokenInfo().GetTokenInfo(baseInfo.LendToken.String(), chainId)
+
+	lendTokenJson, _ := json.Marshal(models.LendToken{
+		LendFee:    lendFee.String(),
+		TokenLogo:  lendToken.Logo,
+		TokenName:  lendToken.Symbol,
+		TokenPrice: lendToken.Price,
+	})
+	borrowTokenJson, _ := json.Marshal(models.BorrowToken{
+		BorrowFee:  borrowFee.String(),
+		TokenLogo:  borrowToken.Logo,
+		TokenName:  borrowToken.Symbol,
+		TokenPrice: borrowToken.Price,
+	})
+
+	poolBase := models.PoolBase{
+		SettleTime:             baseInfo.SettleTime.String(),
+		PoolId:                 utils.StringToInt(poolId),
+		ChainId:                chainId,
+		EndTime:                baseInfo.EndTime.String(),
+		InterestRate:           baseInfo.InterestRate.String(),
+		MaxSupply:              baseInfo.MaxSupply.String(),
+		LendSupply:             baseInfo.LendSupply.String(),
+		BorrowSupply:           baseInfo.BorrowSupply.String(),
+		MartgageRate:           baseInfo.MartgageRate.String(),
+		LendToken:              baseInfo.LendToken.String(),
+		LendTokenSymbol:        lendToken.Symbol,
+		LendTokenInfo:          string(lendTokenJson),
+		BorrowToken:            baseInfo.BorrowToken.String(),
+		BorrowTokenSymbol:      borrowToken.Symbol,
+		BorrowTokenInfo:        string(borrowTokenJson),
+		State:                  utils.IntToString(int(baseInfo.State)),
+		SpCoin:                 baseInfo.SpCoin.String(),
+		JpCoin:                 baseInfo.JpCoin.String(),
+		AutoLiquidateThreshold: baseInfo.AutoLiquidateThreshold.String(),
+	}
+
+	hasInfoData, byteBaseInfoStr, baseInfoMd5Str := s.GetPoolMd5(&poolBase, "base_info:pool_"+chainId+"_"+poolId)
+	if !hasInfoData || (baseInfoMd5Str != byteBaseInfoStr) { // have new data
+		//tokenInfo
+		err = models.NewPoolBase().SavePoolBase(chainId, poolId, &poolBase)
 		if err != nil {
-			log.Logger.Sugar().Info("UpdatePoolInfo PoolBaseInfo err", poolId, err)
-			continue
+			log.Logger.Sugar().Error("SavePoolBase err ", chainId, poolId)
 		}
+		_ = db.RedisSet("base_info:pool_"+chainId+"_"+poolId, baseInfoMd5Str, 60*30) //The expiration time is set to prevent hsah collision
+	}
 
-		hasPoolData, byteDataInfoStr, dataInfoMd5Str := s.GetPoolMd5(&poolBase, "data_info:pool_"+chainId+"_"+poolId)
-		if !hasPoolData || (dataInfoMd5Str != byteDataInfoStr) { // have new data
-			poolData := models.PoolData{
-				PoolId:                 poolId,
-				ChainId:                chainId,
-				FinishAmountBorrow:     dataInfo.FinishAmountBorrow.String(),
-				FinishAmountLend:       dataInfo.FinishAmountLend.String(),
-				LiquidationAmounBorrow: dataInfo.LiquidationAmounBorrow.String(),
-				LiquidationAmounLend:   dataInfo.LiquidationAmounLend.String(),
-				SettleAmountBorrow:     dataInfo.SettleAmountBorrow.String(),
-				SettleAmountLend:       dataInfo.SettleAmountLend.String(),
-			}
-			err = models.NewPoolData().SavePoolData(chainId, poolId, &poolData)
-			if err != nil {
-				log.Logger.Sugar().Error("SavePoolData err ", chainId, poolId)
-			}
-			_ = db.RedisSet("data_info:pool_"+chainId+"_"+poolId, dataInfoMd5Str, 60*30) //The expiration time is set to prevent hsah collision
+	dataInfo, err := pledgePoolToken.PledgePoolTokenCaller.PoolDataInfo(nil, big.NewInt(pid))
+	if err != nil {
+		log.Logger.Sugar().Info("UpdatePoolInfo PoolDataInfo err", poolId, err)
+		return err
+	}
+
+	poolData := models.PoolData{
+		PoolId:                 poolId,
+		ChainId:                chainId,
+		FinishAmountBorrow:     dataInfo.FinishAmountBorrow.String(),
+		FinishAmountLend:       dataInfo.FinishAmountLend.String(),
+		LiquidationAmounBorrow: dataInfo.LiquidationAmounBorrow.String(),
+		LiquidationAmounLend:   dataInfo.LiquidationAmounLend.String(),
+		SettleAmountBorrow:     dataInfo.SettleAmountBorrow.String(),
+		SettleAmountLend:       dataInfo.SettleAmountLend.String(),
+	}
+
+	hasPoolData, byteDataInfoStr, dataInfoMd5Str := s.GetPoolMd5(&poolData, "data_info:pool_"+chainId+"_"+poolId)
+	if !hasPoolData || (dataInfoMd5Str != byteDataInfoStr) { // have new data
+		err = models.NewPoolData().SavePoolData(chainId, poolId, &poolData)
+		if err != nil {
+			log.Logger.Sugar().Error("SavePoolData err ", chainId, poolId)
 		}
+		_ = db.RedisSet("data_info:pool_"+chainId+"_"+poolId, dataInfoMd5Str, 60*30) //The expiration time is set to prevent hsah collision
 	}
+
+	return nil
 }
 
-func (s *poolService) GetPoolMd5(baseInfo *models.PoolBase, key string) (bool, string, string) {
+func (s *poolService) GetPoolMd5(baseInfo interface{}, key string) (bool, string, string) {
 	baseInfoBytes, _ := json.Marshal(baseInfo)
 	baseInfoMd5Str := utils.Md5(string(baseInfoBytes))
 	resInfoBytes, _ := db.RedisGet(key)
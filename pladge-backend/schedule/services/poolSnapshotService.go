@@ -0,0 +1,111 @@
+package services
+
+import (
+	"pledge-backend/config"
+	"pledge-backend/db"
+	"pledge-backend/log"
+	"pledge-backend/schedule/models"
+	"pledge-backend/utils"
+	"strings"
+	"time"
+)
+
+// 池子历史快照：定时采样写入小时桶和日桶，同一个桶保留最后一次采样（相当于按收盘值降采样）
+// 小时桶保留 hourly_retention_days 天，更早的只剩日桶
+type poolSnapshotService struct{}
+
+func NewPoolSnapshot() *poolSnapshotService {
+	return &poolSnapshotService{}
+}
+
+// Snapshot 采样库里各池子当前的 poolbases / pooldata 和借贷币价格，依赖 UpdateAllPoolInfo、UpdateContractPrice 同步的数据
+func (s *poolSnapshotService) Snapshot() {
+
+	var poolBases []models.PoolBase
+	if err := db.Mysql.Table("poolbases").Find(&poolBases).Error; err != nil {
+		log.Logger.Sugar().Error("Snapshot poolbases err ", err)
+		return
+	}
+	var poolData []models.PoolData
+	if err := db.Mysql.Table("pooldata").Find(&poolData).Error; err != nil {
+		log.Logger.Sugar().Error("Snapshot pooldata err ", err)
+		return
+	}
+	var tokens []models.TokenInfo
+	if err := db.Mysql.Table("token_info").Find(&tokens).Error; err != nil {
+		log.Logger.Sugar().Error("Snapshot token_info err ", err)
+		return
+	}
+
+	dataByPool := make(map[string]models.PoolData, len(poolData))
+	for _, d := range poolData {
+		dataByPool[d.ChainId+"_"+d.PoolId] = d
+	}
+	prices := make(map[string]string, len(tokens))
+	for _, t := range tokens {
+		prices[t.ChainId+"_"+strings.ToLower(t.Token)] = t.Price
+	}
+
+	now := time.Now().UTC()
+	hour := now.Truncate(time.Hour).Unix()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Unix()
+
+	snapshots := make([]models.PoolSnapshot, 0, len(poolBases)*2)
+	for _, b := range poolBases {
+		d := dataByPool[b.ChainId+"_"+utils.IntToString(b.PoolId)]
+		snapshot := models.PoolSnapshot{
+			ChainId:                 b.ChainId,
+			PoolId:                  b.PoolId,
+			State:                   b.State,
+			MaxSupply:               b.MaxSupply,
+			LendSupply:              b.LendSupply,
+			BorrowSupply:            b.BorrowSupply,
+			SettleAmountLend:        d.SettleAmountLend,
+			SettleAmountBorrow:      d.SettleAmountBorrow,
+			FinishAmountLend:        d.FinishAmountLend,
+			FinishAmountBorrow:      d.FinishAmountBorrow,
+			LiquidationAmountLend:   d.LiquidationAmounLend,
+			LiquidationAmountBorrow: d.LiquidationAmounBorrow,
+			LendTokenPrice:          prices[b.ChainId+"_"+strings.ToLower(b.LendToken)],
+			BorrowTokenPrice:        prices[b.ChainId+"_"+strings.ToLower(b.BorrowToken)],
+			SampledAt:               now.Unix(),
+		}
+		snapshot.Granularity, snapshot.Bucket = models.SnapshotHour, hour
+		snapshots = append(snapshots, snapshot)
+		snapshot.Granularity, snapshot.Bucket = models.SnapshotDay, day
+		snapshots = append(snapshots, snapshot)
+	}
+
+	if err := models.NewPoolSnapshot().SaveSnapshots(snapshots); err != nil {
+		log.Logger.Sugar().Error("SaveSnapshots err ", err)
+	}
+}
+
+// Prune 按保留天数删除过期的快照，0 表示一直保留
+func (s *poolSnapshotService) Prune() {
+
+	retention := map[string]int{
+		models.SnapshotHour: config.Config.Snapshot.HourlyRetentionDays,
+		models.SnapshotDay:  config.Config.Snapshot.DailyRetentionDays,
+	}
+	for granularity, days := range retention {
+		if days <= 0 {
+			continue
+		}
+		before := time.Now().UTC().AddDate(0, 0, -days).Unix()
+		n, err := models.NewPoolSnapshot().DeleteSnapshotsBefore(granularity, before)
+		if err != nil {
+			log.Logger.Sugar().Error("Prune snapshots err ", granularity, err)
+			continue
+		}
+		log.Logger.Sugar().Info("Prune snapshots ", granularity, " ", n)
+	}
+}
+
+// 采样间隔，未配置时 10 分钟
+func (s *poolSnapshotService) Interval() uint64 {
+	if config.Config.Snapshot.IntervalMinutes == 0 {
+		return 10
+	}
+	return config.Config.Snapshot.IntervalMinutes
+}
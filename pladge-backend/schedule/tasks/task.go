@@ -28,27 +28,41 @@ func Task() {
 	services.NewBalanceMonitor().Monitor()
 	// services.NewTokenPrice().SavePlgrPrice()
 	services.NewTokenPrice().SavePlgrPriceTestNet()
+	services.NewPoolSnapshot().Snapshot()
+	services.NewPoolSnapshot().Prune()
+
+	// 事件驱动：contract-listener 通知哪个池子变了就只刷新哪个，定时全量同步兜底
+	go services.NewPoolRefresh().Subscribe()
+
+	// 关键协议参数变更告警：通知触发 + 定时补发没发出去的
+	services.NewGovernanceAlert().Sweep()
+	go services.NewGovernanceAlert().Subscribe()
 
 	//run pool task
 	s := gocron.NewScheduler()
 	s.ChangeLoc(time.UTC)
-	_ = s.Every(2).Minutes().From(gocron.NextTick()).Do(services.NewPool().UpdateAllPoolInfo)
+	_ = s.Every(services.NewPool().FullSyncInterval()).Minutes().From(gocron.NextTick()).Do(services.NewPool().UpdateAllPoolInfo)
 	_ = s.Every(1).Minute().From(gocron.NextTick()).Do(services.NewTokenPrice().UpdateContractPrice)
 	_ = s.Every(2).Hours().From(gocron.NextTick()).Do(services.NewTokenSymbol().UpdateContractSymbol)
 	_ = s.Every(2).Hours().From(gocron.NextTick()).Do(services.NewTokenLogo().UpdateTokenLogo)
 	_ = s.Every(30).Minutes().From(gocron.NextTick()).Do(services.NewBalanceMonitor().Monitor)
 	//_ = s.Every(30).Minutes().From(gocron.NextTick()).Do(services.NewTokenPrice().SavePlgrPrice)
 	_ = s.Every(30).Minutes().From(gocron.NextTick()).Do(services.NewTokenPrice().SavePlgrPriceTestNet)
+	_ = s.Every(services.NewPoolSnapshot().Interval()).Minutes().From(gocron.NextTick()).Do(services.NewPoolSnapshot().Snapshot)
+	_ = s.Every(1).Day().From(gocron.NextTick()).Do(services.NewPoolSnapshot().Prune)
+	_ = s.Every(1).Minute().From(gocron.NextTick()).Do(services.NewGovernanceAlert().Sweep)
 	<-s.Start() // Start all the pending jobs
 
 }
 
 // 任务,				频率,				理由
 // UpdateContractPrice,1 分钟,价格波动最快，需要最高频更新。
-// UpdateAllPoolInfo,2 分钟,质押池的存借款状态变动较快，需紧密同步。
+// UpdateAllPoolInfo,30 分钟（可配）,池子变化由 listener 通知后单独刷新，全量轮询只兜底漏掉的通知。
 // Monitor (余额),30 分钟,手续费消耗没那么快，半小时检查一次足够安全。
 // SavePlgrPrice,30 分钟,喂价涉及写链（耗 Gas），通常半小时更新一次价格中值即可。
 // UpdateTokenLogo/Symbol,2 小时,代币的名字和图标几乎万年不变，低频更新节省 IO。
+// Snapshot / Prune (池子快照),10 分钟 / 1 天,采样写入小时桶和日桶，过期的小时桶每天清理一次。
+// GovernanceAlert Sweep,1 分钟,补发通知丢失或发送失败的关键参数变更告警。
 
 // 从下至上：MySQL/Redis -> ABI/Env -> Models -> Services -> Tasks。
 